@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// accessTokenPrefix tells personal access tokens apart from JWTs and makes leaked tokens easy to scan for
+const accessTokenPrefix = "gsp_"
+
+const maxAccessTokenLifetime = 365 * 24 * time.Hour
+
+// Scopes a personal access token can be granted
+const (
+	scopePostsRead  = "posts:read"
+	scopePostsWrite = "posts:write"
+	scopeFeedRead   = "feed:read"
+	scopeUsersRead  = "users:read"
+	scopeUsersWrite = "users:write"
+)
+
+type accessTokenKey string
+
+const accessTokenCtx accessTokenKey = "accessToken"
+
+type scopeKey string
+
+const scopeCtx scopeKey = "scope"
+
+type CreateAccessTokenPayload struct {
+	Name      string    `json:"name" validate:"required,max=100"`
+	Scopes    []string  `json:"scopes" validate:"required,min=1,unique,dive,oneof=posts:read posts:write feed:read users:read users:write"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+func (p CreateAccessTokenPayload) validate() error {
+	if err := Validate.Struct(p); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name must not be blank")
+	}
+
+	if !p.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+
+	if p.ExpiresAt.After(time.Now().Add(maxAccessTokenLifetime)) {
+		return errors.New("expires_at must be within a year")
+	}
+
+	return nil
+}
+
+// CreatedAccessToken is returned once, when the token is created. Only its hash is stored
+type CreatedAccessToken struct {
+	store.AccessToken
+	Token string `json:"token"`
+}
+
+// GetAccessTokens godoc
+//
+//	@Summary		Lists personal access tokens
+//	@Description	Lists the authenticated user's unexpired personal access tokens. The tokens themselves are never shown again after creation
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.AccessToken
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) getAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tokens, err := app.store.AccessTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// CreateAccessToken godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a named token limited to the given scopes for scripts and bots. The token is only included in this response
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAccessTokenPayload	true	"Token name, scopes and expiry"
+//	@Success		201		{object}	CreatedAccessToken
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"A token with this name already exists"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAccessTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	secret, err := randomSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	plainToken := accessTokenPrefix + secret
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	at := &store.AccessToken{
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(payload.Name),
+		Scopes:    payload.Scopes,
+		ExpiresAt: payload.ExpiresAt.UTC().Truncate(time.Second),
+	}
+
+	if err := app.store.AccessTokens.Create(r.Context(), at, hashToken); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, errors.New("a token with this name already exists"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, CreatedAccessToken{AccessToken: *at, Token: plainToken}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteAccessToken godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Description	Deletes the token so it stops working immediately
+//	@Tags			users
+//	@Param			tokenID	path	int	true	"Token ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) deleteAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.AccessTokens.Delete(r.Context(), user.ID, tokenID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateAccessToken resolves a personal access token to its user. The token is returned so routes can check
+// its scopes
+func (app *application) authenticateAccessToken(ctx context.Context, token string) (*store.User, *store.AccessToken, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	at, err := app.store.AccessTokens.GetByToken(ctx, hashToken)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, nil, fmt.Errorf("invalid or expired access token")
+		}
+		return nil, nil, err
+	}
+
+	user, err := app.getUser(ctx, at.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := app.store.AccessTokens.Touch(ctx, at.ID); err != nil {
+		app.logger.Errorw("failed to record access token use", "tokenID", at.ID, "error", err.Error())
+	}
+
+	return user, at, nil
+}
+
+// requireScope declares the scope a personal access token needs on the routes it wraps. It has to run before
+// AuthTokenMiddleware, which checks the token against it and refuses personal access tokens on routes that declared
+// no scope. Requests authenticated with a login token have every scope
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), scopeCtx, scope)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// checkScope reports whether the personal access token may be used on this route
+func checkScope(r *http.Request, at *store.AccessToken) bool {
+	scope, _ := r.Context().Value(scopeCtx).(string)
+	return scope != "" && at.HasScope(scope)
+}
+
+// randomSecret returns 32 random bytes encoded to be URL safe
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// requireSession rejects personal access tokens on routes that manage the account itself, such as changing the
+// password or creating more tokens
+func (app *application) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAccessTokenFromContext(r) != nil {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getAccessTokenFromContext(r *http.Request) *store.AccessToken {
+	at, _ := r.Context().Value(accessTokenCtx).(*store.AccessToken)
+	return at
+}
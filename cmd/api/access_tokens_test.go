@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAccessTokens(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	sessionToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path, token, body string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Code
+	}
+
+	expiresAt := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+
+	var created struct {
+		Data CreatedAccessToken `json:"data"`
+	}
+
+	t.Run("should create a token and show it once", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name":"bot","scopes":["users:read"],"expires_at":%q}`, expiresAt)
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/tokens", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+sessionToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(created.Data.Token, accessTokenPrefix) {
+			t.Fatalf("expected a %s token, got %q", accessTokenPrefix, created.Data.Token)
+		}
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name":"admin","scopes":["admin"],"expires_at":%q}`, expiresAt)
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodPost, "/v1/users/me/tokens", sessionToken, body))
+	})
+
+	t.Run("should allow routes within the token's scopes", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(http.MethodGet, "/v1/users/1", created.Data.Token, ""))
+	})
+
+	t.Run("should forbid routes outside the token's scopes", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(http.MethodPut, "/v1/users/2/follow", created.Data.Token, ""))
+	})
+
+	t.Run("should forbid managing the account with a token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(http.MethodGet, "/v1/users/me/tokens", created.Data.Token, ""))
+	})
+
+	t.Run("should forbid routes that declared no scope", func(t *testing.T) {
+		r := chi.NewRouter()
+		r.With(app.AuthTokenMiddleware).Get("/unscoped", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		for token, want := range map[string]int{created.Data.Token: http.StatusForbidden, sessionToken: http.StatusOK} {
+			req := httptest.NewRequest(http.MethodGet, "/unscoped", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			checkResponseCode(t, want, executeRequest(req, r).Code)
+		}
+	})
+
+	t.Run("should stop accepting a revoked token", func(t *testing.T) {
+		path := fmt.Sprintf("/v1/users/me/tokens/%d", created.Data.ID)
+		checkResponseCode(t, http.StatusNoContent, request(http.MethodDelete, path, sessionToken, ""))
+		checkResponseCode(t, http.StatusUnauthorized, request(http.MethodGet, "/v1/users/1", created.Data.Token, ""))
+	})
+}
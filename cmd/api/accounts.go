@@ -0,0 +1,151 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type DeleteAccountPayload struct {
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type AccountDeletion struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// DeactivateAccount godoc
+//
+//	@Summary		Deactivates the account
+//	@Description	Hides the profile, posts and comments until the user logs in again
+//	@Tags			users
+//	@Success		204
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/deactivate [post]
+func (app *application) deactivateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.Deactivate(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteAccount godoc
+//
+//	@Summary		Deletes the account
+//	@Description	Requires the password. The account is deactivated right away and purged after a grace period; logging in before then cancels the deletion
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DeleteAccountPayload	true	"Current password"
+//	@Success		202		{object}	AccountDeletion
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Password is incorrect"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DeleteAccountPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.checkCurrentPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	grace := app.config.accounts.deletionGrace
+
+	if err := app.store.Users.ScheduleDeletion(ctx, user.ID, grace); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	deletion := &AccountDeletion{DeletionScheduledAt: time.Now().Add(grace).Truncate(time.Second)}
+	if err := app.jsonResponse(w, http.StatusAccepted, deletion); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ExportAccount godoc
+//
+//	@Summary		Exports the account's data
+//	@Description	Downloads a zip archive with the profile, posts, comments, followers and following as JSON files
+//	@Tags			users
+//	@Produce		application/zip
+//	@Success		200	{file}		file
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [get]
+func (app *application) exportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	export, err := app.store.Exports.Export(r.Context(), user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"posts.json", export.Posts},
+		{"comments.json", export.Comments},
+		{"followers.json", export.Followers},
+		{"following.json", export.Following},
+	}
+
+	filename := fmt.Sprintf("gosocial-export-%s-%s.zip", export.Profile.Username, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent so a failure past this point can only be logged
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err == nil {
+			enc := json.NewEncoder(fw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(f.data)
+		}
+
+		if err != nil {
+			app.logger.Errorw("failed to write data export", "userID", user.ID, "file", f.name, "error", err.Error())
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		app.logger.Errorw("failed to write data export", "userID", user.ID, "error", err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestDeactivateAccount(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should deactivate the account", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/deactivate", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("should require authentication", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/deactivate", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	grace := time.Hour * 24 * 30
+	app := newTestApplication(t, config{accounts: accountsConfig{deletionGrace: grace}})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &store.User{ID: 1}
+	if err := user.Password.Set("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.store.Users.ChangePassword(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAccount := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should require the password", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, deleteAccount(`{}`).StatusCode)
+	})
+
+	t.Run("should reject an incorrect password", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, deleteAccount(`{"password":"wrong"}`).StatusCode)
+	})
+
+	t.Run("should schedule the deletion after the grace period", func(t *testing.T) {
+		res := deleteAccount(`{"password":"correct horse"}`)
+		checkResponseCode(t, http.StatusAccepted, res.StatusCode)
+
+		var body struct {
+			Data AccountDeletion `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if d := time.Until(body.Data.DeletionScheduledAt); d < grace-time.Minute || d > grace {
+			t.Errorf("expected the deletion in about %s, got %s", grace, d)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/mailer"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// resendActivationHandler godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Sends a new activation link to an account that hasn't been activated, invalidating the previous one. The response is the same whether or not such an account exists
+//	@Tags			Authentication
+//	@Accept			json
+//	@Param			payload	body	ResendActivationPayload	true	"Account email"
+//	@Success		202
+//	@Failure		400	{object}	error
+//	@Failure		429	{object}	error
+//	@Router			/authentication/resend-activation [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Limited per address whether or not it has an account, so the limit doesn't reveal anything either
+	if allow, retryAfter := app.activationLimiter.Allow(strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	if err := app.resendActivation(r, payload.Email); err != nil {
+		app.logger.Errorw("failed to resend activation", "error", err.Error())
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *application) resendActivation(r *http.Request, email string) error {
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	user, err := app.store.Users.RotateInvitation(r.Context(), email, hashToken, app.config.mail.exp)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	// Sent in the background so unknown emails don't answer noticeably faster
+	go func() {
+		status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("failed to send activation email", "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status, "email", user.Email)
+	}()
+
+	return nil
+}
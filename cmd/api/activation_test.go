@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/ratelimiter"
+)
+
+func TestResendActivation(t *testing.T) {
+	cfg := config{
+		accounts: accountsConfig{
+			resendActivationLimit: ratelimiter.Config{
+				RequestsPerTimeFrame: 1,
+				TimeFrame:            time.Minute,
+				MaxClients:           2,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	resend := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/resend-activation", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should require a valid email", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, resend(`{"email":"not-an-email"}`))
+	})
+
+	t.Run("should not reveal unknown emails", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, resend(`{"email":"nobody@example.com"}`))
+	})
+
+	t.Run("should limit resends per email", func(t *testing.T) {
+		checkResponseCode(t, http.StatusTooManyRequests, resend(`{"email":"NOBODY@example.com"}`))
+		checkResponseCode(t, http.StatusAccepted, resend(`{"email":"someone@example.com"}`))
+	})
+
+	t.Run("should still accept new emails once the limiter is full", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, resend(`{"email":"another@example.com"}`))
+		checkResponseCode(t, http.StatusTooManyRequests, resend(`{"email":"another@example.com"}`))
+	})
+}
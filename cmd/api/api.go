@@ -6,8 +6,10 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,7 +22,9 @@ import (
 	"github.com/u-iDaniel/go-social-app/docs"
 	"github.com/u-iDaniel/go-social-app/internal/auth"
 	"github.com/u-iDaniel/go-social-app/internal/env"
+	"github.com/u-iDaniel/go-social-app/internal/events"
 	"github.com/u-iDaniel/go-social-app/internal/mailer"
+	"github.com/u-iDaniel/go-social-app/internal/oidc"
 	"github.com/u-iDaniel/go-social-app/internal/ratelimiter"
 	"github.com/u-iDaniel/go-social-app/internal/store"
 	"github.com/u-iDaniel/go-social-app/internal/store/cache"
@@ -34,6 +38,15 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	// activationLimiter limits activation email resends per address
+	activationLimiter ratelimiter.Limiter
+	// passwordResetLimiter limits password reset emails per address
+	passwordResetLimiter ratelimiter.Limiter
+	broker               events.Broker
+	// secretBox encrypts TOTP secrets before they are stored
+	secretBox *auth.SecretBox
+	// oidcProviders are the external login providers by the name used in their routes
+	oidcProviders map[string]*oidc.Provider
 }
 
 type config struct {
@@ -46,6 +59,44 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	stream      streamConfig
+	suggestions suggestionsConfig
+	accounts    accountsConfig
+	usernames   usernamesConfig
+	oidc        oidcConfig
+}
+
+type oidcConfig struct {
+	providers map[string]oidc.Config
+	stateExp  time.Duration // how long a user has to log in at the provider
+}
+
+type usernamesConfig struct {
+	changeCooldown time.Duration
+	redirectFor    time.Duration // how long an old username keeps resolving to the account
+}
+
+type accountsConfig struct {
+	deletionGrace  time.Duration
+	deletionPolicy string // store.DeletionPolicyAnonymize or store.DeletionPolicyRemove
+	purgeInterval  time.Duration
+	// Accounts not activated this long after registering are deleted by the invitation sweep
+	unactivatedRetention    time.Duration
+	invitationSweepInterval time.Duration
+	resendActivationLimit   ratelimiter.Config
+	passwordResetLimit      ratelimiter.Config
+}
+
+type suggestionsConfig struct {
+	refreshInterval time.Duration
+	perUser         int
+}
+
+type streamConfig struct {
+	heartbeat    time.Duration
+	replayWindow time.Duration
+	historySize  int
+	ticketExp    time.Duration // how long a browser has to open the stream with a ticket, and to reconnect with it after
 }
 
 type redisConfig struct {
@@ -58,6 +109,26 @@ type redisConfig struct {
 type authConfig struct {
 	basic basicConfig
 	token tokenConfig
+	totp  totpConfig
+	login loginConfig
+}
+
+// loginConfig throttles password logins. Failures are counted per account and per IP within failureWindow; past the
+// free attempts each failure doubles the wait from backoffBase, and lockoutThreshold failures lock the account for
+// lockoutDuration
+type loginConfig struct {
+	freeAttempts     int
+	ipFreeAttempts   int
+	lockoutThreshold int
+	backoffBase      time.Duration
+	lockoutDuration  time.Duration
+	failureWindow    time.Duration
+}
+
+type totpConfig struct {
+	issuer        string // shown next to the account in authenticator apps
+	encryptionKey []byte // 32 byte AES key for secrets at rest
+	challengeExp  time.Duration
 }
 
 type basicConfig struct {
@@ -66,9 +137,14 @@ type basicConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret        string
+	exp           time.Duration
+	refreshExp    time.Duration
+	iss           string
+	sweepInterval time.Duration // how often expired refresh tokens and denylist entries are deleted
+	// PEM key files for RS256 or EdDSA signing; secret is only used when signingKeyFile is empty
+	signingKeyFile       string
+	verificationKeyFiles string // comma separated
 }
 
 type mailConfig struct {
@@ -76,6 +152,8 @@ type mailConfig struct {
 	sendGrid  sendGridConfig
 	mailTrap  mailTrapConfig
 	exp       time.Duration
+	resetExp  time.Duration
+	changeExp time.Duration
 }
 
 type sendGridConfig struct {
@@ -103,14 +181,16 @@ func (app *application) mount() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{env.GetString("FRONTEND_URL", "http://localhost:5173")},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "If-Match", "If-None-Match", "If-Modified-Since", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link", "ETag", "Last-Modified"},
+		AllowCredentials: true, // the OIDC callback needs its state cookie
 		MaxAge:           300,
 	}))
 	r.Use(app.RateLimiterMiddleware)
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(app.TimeoutMiddleware(60 * time.Second))
+
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
@@ -119,47 +199,157 @@ func (app *application) mount() http.Handler {
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
+		// requireScope goes before AuthTokenMiddleware, which refuses personal access tokens on routes without one
 		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
+			r.With(app.requireScope(scopePostsWrite), app.AuthTokenMiddleware).Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+				r.With(app.requireScope(scopePostsRead), app.AuthTokenMiddleware, app.postsContextMiddleware).
+					Get("/", app.getPostHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireScope(scopePostsWrite), app.AuthTokenMiddleware, app.postsContextMiddleware)
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.Post("/comments", app.createCommentHandler)
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/confirm-email/{token}", app.confirmEmailHandler)
 
-			r.Route("/{userID}", func(r chi.Router) {
+			r.Route("/me", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Use(app.requireSession)
+
+				r.Patch("/", app.updateProfileHandler)
+				r.Delete("/", app.deleteAccountHandler)
+				r.Post("/deactivate", app.deactivateAccountHandler)
+				r.Get("/export", app.exportAccountHandler)
+				r.Patch("/privacy", app.updatePrivacyHandler)
+				r.Put("/password", app.changePasswordHandler)
+				r.Put("/username", app.changeUsernameHandler)
+				r.Put("/email", app.changeEmailHandler)
+				r.Get("/blocks", app.getBlockedUsersHandler)
+				r.Get("/mutes", app.getMutedUsersHandler)
+				r.Get("/suggestions", app.getSuggestionsHandler)
+				r.Delete("/suggestions/{userID}", app.dismissSuggestionHandler)
+
+				r.Post("/identities/{provider}", app.linkIdentityHandler)
+
+				r.Route("/sessions", func(r chi.Router) {
+					r.Get("/", app.getSessionsHandler)
+					r.Delete("/{sessionID}", app.revokeSessionHandler)
+				})
+
+				r.Route("/tokens", func(r chi.Router) {
+					r.Get("/", app.getAccessTokensHandler)
+					r.Post("/", app.createAccessTokenHandler)
+					r.Delete("/{tokenID}", app.deleteAccessTokenHandler)
+				})
+
+				r.Route("/two-factor", func(r chi.Router) {
+					r.Post("/", app.enrollTwoFactorHandler)
+					r.Delete("/", app.disableTwoFactorHandler)
+					r.Post("/enable", app.enableTwoFactorHandler)
+					r.Post("/recovery-codes", app.regenerateRecoveryCodesHandler)
+				})
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getIncomingFollowRequestsHandler)
+					r.Put("/{userID}/approve", app.approveFollowRequestHandler)
+					r.Put("/{userID}/reject", app.rejectFollowRequestHandler)
+					r.Get("/outgoing", app.getOutgoingFollowRequestsHandler)
+					r.Delete("/outgoing/{userID}", app.cancelFollowRequestHandler)
+				})
+
+				r.Route("/muted-words", func(r chi.Router) {
+					r.Get("/", app.getMutedWordsHandler)
+					r.Post("/", app.createMutedWordHandler)
+					r.Put("/{mutedWordID}", app.updateMutedWordHandler)
+					r.Delete("/{mutedWordID}", app.deleteMutedWordHandler)
+				})
 			})
 
-			r.Group(func(r chi.Router) {
-				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+			r.Route("/{userID}", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireScope(scopeUsersRead), app.AuthTokenMiddleware)
+					r.Get("/", app.getUserHandler)
+					r.Get("/followers", app.getFollowersHandler)
+					r.Get("/following", app.getFollowingHandler)
+					r.Get("/relationship", app.getRelationshipHandler)
+				})
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireScope(scopeUsersWrite), app.AuthTokenMiddleware)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Put("/unblock", app.unblockUserHandler)
+					r.Put("/mute", app.muteUserHandler)
+					r.Put("/unmute", app.unmuteUserHandler)
+				})
+
+				r.With(app.AuthTokenMiddleware, app.requireSession, app.requireRole("admin")).
+					Delete("/sessions", app.revokeUserSessionsHandler)
 			})
 
+			r.With(app.requireScope(scopeFeedRead), app.AuthTokenMiddleware).Get("/feed", app.getUserFeedHandler)
+			r.With(app.requireScope(scopeUsersRead), app.AuthTokenMiddleware).Get("/search", app.searchUsersHandler)
+			r.With(app.requireScope(scopeUsersRead), app.AuthTokenMiddleware).
+				Get("/by-username/{username}", app.getUserByUsernameHandler)
+
+		})
+
+		r.With(app.requireScope(scopePostsRead), app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+		r.With(app.requireScope(scopeFeedRead), app.streamAuthMiddleware).Get("/stream", app.streamHandler)
+		r.With(app.requireScope(scopeFeedRead), app.AuthTokenMiddleware).Post("/stream/ticket", app.createStreamTicketHandler)
+
+		r.Route("/feeds", func(r chi.Router) {
+			r.Get("/users/{username}", app.userTimelineFeedHandler)
+			r.Get("/tags/{tag}", app.tagFeedHandler)
 		})
 
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/token/two-factor", app.twoFactorTokenHandler)
+			r.Get("/oidc/{provider}/login", app.oidcLoginHandler)
+			r.Post("/oidc/{provider}/callback", app.oidcCallbackHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.With(app.AuthTokenMiddleware, app.requireSession).Post("/logout", app.logoutHandler)
+			r.With(app.AuthTokenMiddleware, app.requireSession).Post("/logout/all", app.logoutAllHandler)
+			r.Post("/resend-activation", app.resendActivationHandler)
+			r.Post("/password-reset", app.forgotPasswordHandler)
+			r.Post("/password-reset/confirm", app.resetPasswordHandler)
 		})
 	})
 
 	return r
 }
 
+// externalURL is where clients reach the API, from EXTERNAL_URL. A bare host:port is read as http
+func (app *application) externalURL() *url.URL {
+	raw := app.config.apiURL
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return &url.URL{Scheme: "http", Host: app.config.apiURL}
+	}
+
+	return u
+}
+
 func (app *application) run(mux http.Handler) error {
 	docs.SwaggerInfo.Version = version
-	docs.SwaggerInfo.Host = app.config.apiURL
+	externalURL := app.externalURL()
+	docs.SwaggerInfo.Host = externalURL.Host
+	docs.SwaggerInfo.Schemes = []string{externalURL.Scheme}
 	docs.SwaggerInfo.BasePath = "/v1"
 
 	server := &http.Server{
@@ -172,6 +362,9 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs := app.startJobs(jobsCtx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -183,7 +376,16 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
-		shutdown <- server.Shutdown(ctx)
+		// Event streams never go idle on their own so end them before waiting on in-flight requests
+		if err := app.broker.Close(); err != nil {
+			app.logger.Errorw("failed to close event broker", "error", err.Error())
+		}
+
+		stopJobs()
+		err := server.Shutdown(ctx)
+		jobs.Wait()
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
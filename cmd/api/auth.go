@@ -14,7 +14,7 @@ import (
 )
 
 type RegisterUserPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
+	Username string `json:"username" validate:"required,username"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
 }
@@ -24,6 +24,14 @@ type UserWithToken struct {
 	Token string `json:"token"`
 }
 
+// TokenPair is handed out on login: a short-lived access token for the Authorization header and a refresh token
+// that can be exchanged once for a new pair
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds until token expires
+}
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
@@ -121,14 +129,16 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for the user
+//	@Description	Creates a short-lived access token and a refresh token for the user. Accounts with two-factor authentication get a challenge to complete at /authentication/token/two-factor instead
 //	@Tags			Authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		201		{object}	string					"Token"
+//	@Success		201		{object}	TokenPair				"Tokens"
+//	@Success		200		{object}	TwoFactorChallenge		"A second factor is required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed logins; see Retry-After"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -143,38 +153,136 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	ctx := r.Context()
+	keys := newLoginKeys(r, payload.Email)
+
+	lockedFor, err := app.loginLockedFor(ctx, keys)
 	if err != nil {
-		switch err {
-		case store.ErrNotFound:
-			app.unauthorizedErrorResponse(w, r, err)
-		default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return
+	}
+
+	// Unknown emails go through a password check too so they take as long to reject as a wrong password
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	var valid bool
+	switch err {
+	case nil:
+		valid = user.Password.Check(payload.Password)
+	case store.ErrNotFound:
+		user, valid = nil, store.CheckDummyPassword(payload.Password)
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !valid {
+		if err := app.recordLoginFailure(ctx, r, keys, user); err != nil {
 			app.internalServerError(w, r, err)
+			return
 		}
+
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid credentials"))
 		return
 	}
 
-	if !user.Password.Check(payload.Password) {
-		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid credentials"))
+	if user.TwoFactorEnabled {
+		app.twoFactorChallengeResponse(w, r, user)
 		return
 	}
 
+	app.completeLogin(w, r, user)
+}
+
+// completeLogin issues tokens once every factor checked out and clears the account's failed logins. Logging in is
+// how a deactivated account comes back, which also cancels a scheduled deletion
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if err := app.store.LoginFailures.Reset(r.Context(), newLoginKeys(r, user.Email).account); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user.DeactivatedAt != nil {
+		if err := app.store.Users.Reactivate(r.Context(), user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.invalidateUser(r.Context(), user.ID)
+	}
+
+	tokens, err := app.issueTokens(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// issueTokens signs an access token and starts a new refresh token family for the user, recorded as a session on
+// the device making the request
+func (app *application) issueTokens(r *http.Request, user *store.User) (*TokenPair, error) {
+	ctx := r.Context()
+	familyID := uuid.New().String()
+
+	token, jti, err := app.issueToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	plainRefresh := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainRefresh))
+	hashRefresh := hex.EncodeToString(hash[:])
+
+	rt := &store.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenGeneration: user.TokenGeneration,
+	}
+
+	if err := app.store.RefreshTokens.Create(ctx, rt, hashRefresh, app.config.auth.token.refreshExp); err != nil {
+		return nil, err
+	}
+
+	if err := app.saveSession(r, user.ID, familyID, jti); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		Token:        token,
+		RefreshToken: plainRefresh,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}, nil
+}
+
+// issueToken signs an access token for the user at their current token generation and returns it with its ID.
+// sessionID is the refresh token family the token belongs to, so logging out can revoke both
+func (app *application) issueToken(user *store.User, sessionID string) (string, string, error) {
+	jti := uuid.New().String()
+
 	claims := jwt.MapClaims{
 		"sub": user.ID,
+		"jti": jti,
+		"sid": sessionID,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.iss,
+		"gen": user.TokenGeneration,
 	}
 
 	token, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+		return "", "", err
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
-		app.internalServerError(w, r, err)
-	}
+	return token, jti, nil
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestBlockUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	ctx := context.Background()
+
+	tokenFor := func(userID int64) string {
+		token, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"sub": userID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	request := func(userID int64, method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokenFor(userID))
+		return executeRequest(req, mux).Result()
+	}
+
+	relationship := func(viewerID, targetID int64) *store.Relationship {
+		rel, err := app.store.Followers.GetRelationship(ctx, viewerID, targetID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rel
+	}
+
+	if err := app.store.Users.Delete(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	adaPost := &store.Post{Title: "Ada's post", Content: "Hello", UserID: 2}
+	gracePost := &store.Post{Title: "Grace's post", Content: "Hi", UserID: 1}
+	for _, p := range []*store.Post{adaPost, gracePost} {
+		if err := app.store.Posts.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// User 1 and user 2 follow each other before the block
+	checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+	checkResponseCode(t, http.StatusNoContent, request(2, http.MethodPut, "/v1/users/1/follow", "").StatusCode)
+	checkResponseCode(t, http.StatusCreated, request(1, http.MethodPost, fmt.Sprintf("/v1/posts/%d/comments", adaPost.ID), `{"content":"Nice"}`).StatusCode)
+
+	t.Run("should not block yourself", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/1/block", "").StatusCode)
+	})
+
+	t.Run("should not block an unknown user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodPut, "/v1/users/99/block", "").StatusCode)
+	})
+
+	t.Run("should block a user and remove follows both ways", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/block", "").StatusCode)
+
+		rel := relationship(1, 2)
+		if !rel.Blocking || rel.Following || rel.FollowedBy {
+			t.Errorf("expected a block and no follows, got %+v", rel)
+		}
+
+		if rel := relationship(2, 1); !rel.BlockedBy {
+			t.Errorf("expected the blocked user to see the block, got %+v", rel)
+		}
+
+		res := request(1, http.MethodGet, "/v1/users/me/blocks", "")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.BlockedUser `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(body.Data) != 1 || body.Data[0].UserID != 2 {
+			t.Errorf("expected user 2 on the block list, got %+v", body.Data)
+		}
+	})
+
+	t.Run("should not block twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, request(1, http.MethodPut, "/v1/users/2/block", "").StatusCode)
+	})
+
+	t.Run("should refuse follows in both directions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(1, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+		checkResponseCode(t, http.StatusForbidden, request(2, http.MethodPut, "/v1/users/1/follow", "").StatusCode)
+	})
+
+	t.Run("should hide posts in both directions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodGet, fmt.Sprintf("/v1/posts/%d", adaPost.ID), "").StatusCode)
+		checkResponseCode(t, http.StatusNotFound, request(2, http.MethodGet, fmt.Sprintf("/v1/posts/%d", gracePost.ID), "").StatusCode)
+	})
+
+	t.Run("should reject comments in both directions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodPost, fmt.Sprintf("/v1/posts/%d/comments", adaPost.ID), `{"content":"Hi"}`).StatusCode)
+		checkResponseCode(t, http.StatusNotFound, request(2, http.MethodPost, fmt.Sprintf("/v1/posts/%d/comments", gracePost.ID), `{"content":"Hi"}`).StatusCode)
+	})
+
+	t.Run("should not unblock a user who isn't blocked", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(2, http.MethodPut, "/v1/users/1/unblock", "").StatusCode)
+	})
+
+	t.Run("should unblock without restoring follows", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/unblock", "").StatusCode)
+
+		if rel := relationship(1, 2); rel.Blocking || rel.Following || rel.FollowedBy {
+			t.Errorf("expected no block and no follows, got %+v", rel)
+		}
+
+		checkResponseCode(t, http.StatusOK, request(1, http.MethodGet, fmt.Sprintf("/v1/posts/%d", adaPost.ID), "").StatusCode)
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+	})
+
+	t.Run("should reject an invalid user ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/abc/block", "").StatusCode)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// weakETag builds a weak entity tag from the values that make up a representation
+func weakETag(parts ...any) string {
+	return `W/"` + etagHash(parts...) + `"`
+}
+
+func etagHash(parts ...any) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%v|", part)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// checkNotModified sets the ETag and Last-Modified validators on the response and reports whether the request's
+// If-None-Match / If-Modified-Since headers show the client already has this representation. When it returns true
+// a 304 has been written and the handler should return without a body.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.1.3)
+		notModified = etag != "" && etagMatches(inm, etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			// HTTP dates only have second precision
+			notModified = !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+	}
+
+	return notModified
+}
+
+// etagMatches compares a list of entity tags from a conditional header against etag using weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDBTime parses the timestamps the store scans into strings
+func parseDBTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestCheckNotModified(t *testing.T) {
+	etag := weakETag(1, 2, "2025-08-08T12:00:00Z")
+	lastModified := time.Date(2025, 8, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no conditional headers", nil, false},
+		{"matching If-None-Match", map[string]string{"If-None-Match": etag}, true},
+		{"strong form of the weak ETag", map[string]string{"If-None-Match": etag[2:]}, true},
+		{"one of several", map[string]string{"If-None-Match": `"abc", ` + etag}, true},
+		{"stale If-None-Match", map[string]string{"If-None-Match": `W/"stale"`}, false},
+		{"If-Modified-Since at last modification", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{"If-Modified-Since before last modification", map[string]string{"If-Modified-Since": lastModified.Add(-time.Minute).Format(http.TimeFormat)}, false},
+		{"If-None-Match wins over If-Modified-Since", map[string]string{
+			"If-None-Match":     `W/"stale"`,
+			"If-Modified-Since": lastModified.Format(http.TimeFormat),
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rr := httptest.NewRecorder()
+			if got := checkNotModified(rr, req, etag, lastModified); got != tt.want {
+				t.Errorf("checkNotModified() = %v, want %v", got, tt.want)
+			}
+
+			if rr.Header().Get("ETag") != etag {
+				t.Errorf("expected ETag %s to be set, got %q", etag, rr.Header().Get("ETag"))
+			}
+
+			if tt.want {
+				checkResponseCode(t, http.StatusNotModified, rr.Code)
+			}
+		})
+	}
+}
+
+func TestPostETag(t *testing.T) {
+	post := &store.Post{ID: 1, Version: 2, Comments: []store.Comment{{ID: 10, Content: "first"}}}
+	etag := postETag(post)
+
+	commented := *post
+	commented.Comments = append(commented.Comments, store.Comment{ID: 11, Content: "second"})
+	if postETag(&commented) == etag {
+		t.Error("expected a new comment to change the ETag")
+	}
+
+	uncommented := *post
+	uncommented.Comments = nil
+	if postETag(&uncommented) == etag {
+		t.Error("expected a deleted comment to change the ETag")
+	}
+
+	if !postVersionMatches(etag, &commented) {
+		t.Error("expected If-Match to ignore new comments")
+	}
+
+	if !postVersionMatches(`"abc", `+etag[2:], post) {
+		t.Error("expected If-Match to accept the strong form in a list")
+	}
+
+	edited := *post
+	edited.Version++
+	if postVersionMatches(etag, &edited) {
+		t.Error("expected If-Match to fail once the post was edited")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/mailer"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=72"`
+}
+
+type ChangeEmailPayload struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// ChangePassword godoc
+//
+//	@Summary		Changes the password
+//	@Description	Requires the current password. Every existing access and refresh token is revoked and a new pair is returned
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	TokenPair				"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Current password is incorrect"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [put]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, ok := app.checkCurrentPassword(w, r, payload.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	if err := user.Password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ChangePassword(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	// The tokens used by this client were just revoked so hand out a pair for the new generation
+	tokens, err := app.issueTokens(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ChangeEmail godoc
+//
+//	@Summary		Starts an email change
+//	@Description	Requires the current password. Sends a confirmation link to the new address and a notice to the current one; the email only changes once the link is confirmed. Addresses used by another account get the same response but no emails
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body	ChangeEmailPayload	true	"New email and current password"
+//	@Success		202
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error	"Current password is incorrect"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [put]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.checkCurrentPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	if strings.EqualFold(payload.NewEmail, user.Email) {
+		app.badRequestResponse(w, r, errors.New("new email is the same as the current one"))
+		return
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err := app.store.Users.CreateEmailChange(r.Context(), user.ID, payload.NewEmail, hashToken, app.config.mail.changeExp)
+	switch err {
+	case nil:
+		app.sendEmailChangeMails(user, payload.NewEmail, plainToken)
+	case store.ErrDuplicateEmail:
+		// Answered like a free address, with no emails sent, so this can't be used to find out who is registered
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendEmailChangeMails sends the confirmation link to the new address and a notice to the current one. In the
+// background so the response takes as long as for an address that is already taken
+func (app *application) sendEmailChangeMails(user *store.User, newEmail, plainToken string) {
+	isProdEnv := app.config.env == "production"
+	confirmVars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  app.config.mail.changeExp.String(),
+	}
+
+	noticeVars := struct {
+		Username string
+		NewEmail string
+	}{
+		Username: user.Username,
+		NewEmail: newEmail,
+	}
+
+	go func() {
+		status, err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, newEmail, confirmVars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("failed to send email change confirmation", "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status, "email", newEmail)
+
+		if _, err := app.mailer.Send(mailer.EmailNoticeTemplate, user.Username, user.Email, noticeVars, !isProdEnv); err != nil {
+			app.logger.Errorw("failed to send email change notice", "error", err)
+		}
+	}()
+}
+
+// ConfirmEmail godoc
+//
+//	@Summary		Confirms an email change
+//	@Description	Switches the account to the new address using the token from the confirmation email
+//	@Tags			users
+//	@Param			token	path	string	true	"Confirmation token"
+//	@Success		204
+//	@Failure		400	{object}	error	"Email already in use"
+//	@Failure		404	{object}	error	"Invalid or expired token"
+//	@Failure		500	{object}	error
+//	@Router			/users/confirm-email/{token} [put]
+func (app *application) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	ctx := r.Context()
+
+	userID, err := app.store.Users.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCurrentPassword loads the authenticated user with their password hash, which the cached copy doesn't have,
+// and checks it against password. A 403 has been sent when it returns false
+func (app *application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, password string) (*store.User, bool) {
+	user, err := app.store.Users.GetByID(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if !user.Password.Check(password) {
+		app.forbiddenResponse(w, r)
+		return nil, false
+	}
+
+	return user, true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/u-iDaniel/go-social-app/internal/store"
 )
@@ -9,7 +10,7 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the user feed. Posts matching the user's muted words, phrases and tags are left out
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
@@ -21,6 +22,7 @@ import (
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
 //	@Success		200		{object}	[]store.PostWithMetadata
+//	@Success		304
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -45,13 +47,29 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	user := getUserFromContext(r)
+
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(42), fq)
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	// The feed differs per user so caches must key on the Authorization header
+	w.Header().Set("Vary", "Authorization")
+
+	// Posts drop out of the feed on unfollows, blocks, mutes and deletes without anything getting newer, so only
+	// the ETag can tell whether the feed changed
+	etagParts := []any{fq.Limit, fq.Offset, fq.Sort, fq.Tags, fq.Search}
+	for _, p := range feed {
+		etagParts = append(etagParts, p.ID, p.Version, p.CommentCount)
+	}
+
+	if checkNotModified(w, r, weakETag(etagParts...), time.Time{}) {
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/events"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type FollowRequestStatus struct {
+	Status string `json:"status"`
+}
+
+type UpdatePrivacyPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, requester, target *store.User) {
+	ctx := r.Context()
+
+	if err := app.store.FollowRequests.Create(ctx, requester.ID, target.ID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	data := map[string]any{"id": requester.ID, "username": requester.Username}
+	app.publishEvent(ctx, events.TypeFollowRequest, requester.ID, []int64{target.ID}, data)
+
+	if err := app.jsonResponse(w, http.StatusAccepted, &FollowRequestStatus{Status: "requested"}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetIncomingFollowRequests godoc
+//
+//	@Summary		Lists incoming follow requests
+//	@Description	Lists pending requests to follow the authenticated user's private account
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getIncomingFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	app.followRequestListResponse(w, r, true)
+}
+
+// GetOutgoingFollowRequests godoc
+//
+//	@Summary		Lists outgoing follow requests
+//	@Description	Lists the authenticated user's pending requests to follow private accounts
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/outgoing [get]
+func (app *application) getOutgoingFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	app.followRequestListResponse(w, r, false)
+}
+
+func (app *application) followRequestListResponse(w http.ResponseWriter, r *http.Request, incoming bool) {
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	var requests []store.FollowRequest
+	if incoming {
+		requests, err = app.store.FollowRequests.GetIncoming(r.Context(), user.ID, pq)
+	} else {
+		requests, err = app.store.FollowRequests.GetOutgoing(r.Context(), user.ID, pq)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Approves a pending request so the requester follows the authenticated user
+//	@Tags			users
+//	@Param			userID	path	int64	true	"Requester user ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No pending request"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.FollowRequests.Approve(r.Context(), requesterID, user.ID); err != nil {
+		app.followRequestErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects a pending request to follow the authenticated user
+//	@Tags			users
+//	@Param			userID	path	int64	true	"Requester user ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No pending request"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.FollowRequests.Delete(r.Context(), requesterID, user.ID); err != nil {
+		app.followRequestErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelFollowRequest godoc
+//
+//	@Summary		Cancels a follow request
+//	@Description	Withdraws the authenticated user's pending request to follow a private account
+//	@Tags			users
+//	@Param			userID	path	int64	true	"Target user ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No pending request"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/outgoing/{userID} [delete]
+func (app *application) cancelFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.FollowRequests.Delete(r.Context(), user.ID, targetID); err != nil {
+		app.followRequestErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdatePrivacy godoc
+//
+//	@Summary		Makes the account private or public
+//	@Description	Private accounts approve followers and only show posts to them. Going public approves pending requests
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body	UpdatePrivacyPayload	true	"Privacy setting"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/privacy [patch]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdatePrivacyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) followRequestErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		app.notFoundResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestFollowRequests(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	ctx := context.Background()
+
+	tokenFor := func(userID int64) string {
+		token, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"sub": userID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	request := func(userID int64, method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokenFor(userID))
+		return executeRequest(req, mux).Result()
+	}
+
+	following := func(viewerID, targetID int64) bool {
+		rel, err := app.store.Followers.GetRelationship(ctx, viewerID, targetID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rel.Following
+	}
+
+	listRequests := func(userID int64, path string) []store.FollowRequest {
+		res := request(userID, http.MethodGet, path, "")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.FollowRequest `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	// User 2 has a private account
+	checkResponseCode(t, http.StatusNoContent, request(2, http.MethodPatch, "/v1/users/me/privacy", `{"is_private":true}`).StatusCode)
+
+	post := &store.Post{Title: "Private post", Content: "Hello", UserID: 2, User: store.User{ID: 2, IsPrivate: true}}
+	if err := app.store.Posts.Create(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+	postPath := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	t.Run("should require is_private", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(2, http.MethodPatch, "/v1/users/me/privacy", `{}`).StatusCode)
+	})
+
+	t.Run("should hide a private account's posts from non-followers", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodGet, postPath, "").StatusCode)
+		checkResponseCode(t, http.StatusOK, request(2, http.MethodGet, postPath, "").StatusCode)
+	})
+
+	t.Run("should send a request to follow a private account", func(t *testing.T) {
+		res := request(1, http.MethodPut, "/v1/users/2/follow", "")
+		checkResponseCode(t, http.StatusAccepted, res.StatusCode)
+
+		var body struct {
+			Data FollowRequestStatus `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Data.Status != "requested" {
+			t.Errorf("expected the request to be pending, got %q", body.Data.Status)
+		}
+
+		if following(1, 2) {
+			t.Error("a request should not follow the account yet")
+		}
+
+		if incoming := listRequests(2, "/v1/users/me/follow-requests"); len(incoming) != 1 || incoming[0].UserID != 1 {
+			t.Errorf("expected a request from user 1, got %+v", incoming)
+		}
+
+		if outgoing := listRequests(1, "/v1/users/me/follow-requests/outgoing"); len(outgoing) != 1 || outgoing[0].UserID != 2 {
+			t.Errorf("expected a request to user 2, got %+v", outgoing)
+		}
+	})
+
+	t.Run("should not send a request twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, request(1, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+	})
+
+	t.Run("should approve a request", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(2, http.MethodPut, "/v1/users/me/follow-requests/1/approve", "").StatusCode)
+
+		if !following(1, 2) {
+			t.Error("expected the requester to follow the account")
+		}
+
+		if incoming := listRequests(2, "/v1/users/me/follow-requests"); len(incoming) != 0 {
+			t.Errorf("expected no pending requests, got %+v", incoming)
+		}
+
+		checkResponseCode(t, http.StatusOK, request(1, http.MethodGet, postPath, "").StatusCode)
+		checkResponseCode(t, http.StatusConflict, request(1, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+	})
+
+	t.Run("should not approve a request that isn't pending", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(2, http.MethodPut, "/v1/users/me/follow-requests/1/approve", "").StatusCode)
+	})
+
+	t.Run("should reject a request", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, request(3, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+		checkResponseCode(t, http.StatusNoContent, request(2, http.MethodPut, "/v1/users/me/follow-requests/3/reject", "").StatusCode)
+
+		if following(3, 2) {
+			t.Error("a rejected request should not follow the account")
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(3, http.MethodGet, postPath, "").StatusCode)
+		checkResponseCode(t, http.StatusNotFound, request(2, http.MethodPut, "/v1/users/me/follow-requests/3/reject", "").StatusCode)
+	})
+
+	t.Run("should cancel a request", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, request(3, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+		checkResponseCode(t, http.StatusNoContent, request(3, http.MethodDelete, "/v1/users/me/follow-requests/outgoing/2", "").StatusCode)
+
+		if incoming := listRequests(2, "/v1/users/me/follow-requests"); len(incoming) != 0 {
+			t.Errorf("expected the request to be withdrawn, got %+v", incoming)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(3, http.MethodDelete, "/v1/users/me/follow-requests/outgoing/2", "").StatusCode)
+	})
+
+	t.Run("should approve pending requests when going public", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, request(4, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+		checkResponseCode(t, http.StatusNoContent, request(2, http.MethodPatch, "/v1/users/me/privacy", `{"is_private":false}`).StatusCode)
+
+		if !following(4, 2) {
+			t.Error("expected the pending request to be approved")
+		}
+
+		checkResponseCode(t, http.StatusNoContent, request(3, http.MethodPut, "/v1/users/2/follow", "").StatusCode)
+	})
+
+	t.Run("should reject an invalid user ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(2, http.MethodPut, "/v1/users/me/follow-requests/abc/approve", "").StatusCode)
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// startJobs launches the periodic background jobs. They stop when ctx is cancelled and the returned WaitGroup is
+// done once every job has returned.
+func (app *application) startJobs(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	app.runPeriodically(ctx, &wg, "refresh follow suggestions", app.config.suggestions.refreshInterval, func(ctx context.Context) error {
+		return app.store.Suggestions.Refresh(ctx, app.config.suggestions.perUser)
+	})
+
+	app.runPeriodically(ctx, &wg, "purge deleted accounts", app.config.accounts.purgeInterval, func(ctx context.Context) error {
+		purged, err := app.store.Users.PurgeDeleted(ctx, app.config.accounts.deletionPolicy)
+		if purged > 0 {
+			app.logger.Infow("purged deleted accounts", "count", purged, "policy", app.config.accounts.deletionPolicy)
+		}
+		return err
+	})
+
+	app.runPeriodically(ctx, &wg, "sweep invitations", app.config.accounts.invitationSweepInterval, func(ctx context.Context) error {
+		expired, freed, err := app.store.Users.SweepInvitations(ctx, app.config.accounts.unactivatedRetention)
+		if expired > 0 || freed > 0 {
+			app.logger.Infow("swept invitations", "expired", expired, "freedAccounts", freed)
+		}
+		return err
+	})
+
+	app.runPeriodically(ctx, &wg, "sweep expired tokens", app.config.auth.token.sweepInterval, func(ctx context.Context) error {
+		refresh, err := app.store.RefreshTokens.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		access, err := app.store.AccessTokens.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		sessions, err := app.store.Sessions.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		logins, err := app.store.LoginFailures.DeleteStale(ctx, app.config.auth.login.failureWindow)
+		if err != nil {
+			return err
+		}
+
+		tickets, err := app.store.StreamTickets.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Redis expires its own denylist entries
+		var revoked int64
+		if !app.config.redisCfg.enabled {
+			if revoked, err = app.store.RevokedTokens.DeleteExpired(ctx); err != nil {
+				return err
+			}
+		}
+
+		if refresh > 0 || access > 0 || sessions > 0 || logins > 0 || tickets > 0 || revoked > 0 {
+			app.logger.Infow("swept expired tokens", "refreshTokens", refresh, "accessTokens", access, "sessions", sessions,
+				"loginFailures", logins, "streamTickets", tickets, "revokedTokens", revoked)
+		}
+		return nil
+	})
+
+	return &wg
+}
+
+// runPeriodically runs fn right away and then once per interval. Failures are logged and retried on the next tick.
+// A zero interval disables the job
+func (app *application) runPeriodically(ctx context.Context, wg *sync.WaitGroup, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			start := time.Now()
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+			} else if err == nil {
+				app.logger.Infow("background job finished", "job", name, "duration", time.Since(start).String())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -11,6 +11,7 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	Validate.RegisterValidation("username", validateUsername)
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/u-iDaniel/go-social-app/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		Publishes token verification keys
+//	@Description	JSON Web Key Set with the public keys access tokens are verified with. Empty when tokens are signed with a shared secret
+//	@Tags			Authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set := auth.JWKSet{Keys: []auth.JWK{}}
+	if publisher, ok := app.authenticator.(auth.KeySetPublisher); ok {
+		set = publisher.JWKS()
+	}
+
+	// Verifiers refetch on an unknown kid, so a short cache is enough to pick up rotations
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// The JWKS format is fixed by RFC 7517 so this skips the usual data envelope
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/u-iDaniel/go-social-app/internal/auth"
+)
+
+func TestJWKS(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	getKeys := func() auth.JWKSet {
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var set auth.JWKSet
+		if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	t.Run("should not publish shared secrets", func(t *testing.T) {
+		if set := getKeys(); len(set.Keys) != 0 {
+			t.Errorf("expected no keys, got %d", len(set.Keys))
+		}
+	})
+
+	t.Run("should publish the signing key", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := auth.NewKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.authenticator, err = auth.NewKeyringAuthenticator(key, nil, "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := getKeys()
+		if len(set.Keys) != 1 || set.Keys[0].KeyID != key.ID {
+			t.Errorf("expected the signing key %q, got %+v", key.ID, set.Keys)
+		}
+	})
+}
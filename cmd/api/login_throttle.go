@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/mailer"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// loginKeys are the hashed keys failed logins are counted under: one for the account and one for the client's IP.
+// Unknown emails get a counter too, so lockouts behave the same whether or not an account exists
+type loginKeys struct {
+	account string
+	ip      string
+}
+
+func newLoginKeys(r *http.Request, email string) loginKeys {
+	return loginKeys{
+		account: hashLoginKey("email:" + strings.ToLower(email)),
+		ip:      hashLoginKey("ip:" + clientIP(r)),
+	}
+}
+
+func hashLoginKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// loginLockedFor returns how long logins with these keys are refused, or 0 when they are allowed
+func (app *application) loginLockedFor(ctx context.Context, keys loginKeys) (time.Duration, error) {
+	until, err := app.store.LoginFailures.LockedUntil(ctx, []string{keys.account, keys.ip})
+	if err != nil {
+		return 0, err
+	}
+
+	if until.IsZero() {
+		return 0, nil
+	}
+
+	return time.Until(until), nil
+}
+
+// recordLoginFailure counts a failed login against the account and the IP. Both back off exponentially once their
+// free attempts are used up; the account is locked outright at the lockout threshold and its owner emailed. user is
+// nil when the email has no account
+func (app *application) recordLoginFailure(ctx context.Context, r *http.Request, keys loginKeys, user *store.User) error {
+	cfg := app.config.auth.login
+
+	failures, err := app.store.LoginFailures.RecordFailure(ctx, keys.account, cfg.failureWindow)
+	if err != nil {
+		return err
+	}
+
+	if cfg.lockoutThreshold > 0 && failures >= cfg.lockoutThreshold {
+		if err := app.store.LoginFailures.Lock(ctx, keys.account, time.Now().Add(cfg.lockoutDuration)); err != nil {
+			return err
+		}
+
+		// Only the failure that crosses the threshold sends a notice, not every one while the lock is renewed
+		if failures == cfg.lockoutThreshold && user != nil {
+			app.sendLockoutNotice(user, failures, clientIP(r))
+		}
+	} else if delay := loginBackoff(failures, cfg.freeAttempts, cfg.backoffBase, cfg.lockoutDuration); delay > 0 {
+		if err := app.store.LoginFailures.Lock(ctx, keys.account, time.Now().Add(delay)); err != nil {
+			return err
+		}
+	}
+
+	failures, err = app.store.LoginFailures.RecordFailure(ctx, keys.ip, cfg.failureWindow)
+	if err != nil {
+		return err
+	}
+
+	if delay := loginBackoff(failures, cfg.ipFreeAttempts, cfg.backoffBase, cfg.lockoutDuration); delay > 0 {
+		return app.store.LoginFailures.Lock(ctx, keys.ip, time.Now().Add(delay))
+	}
+
+	return nil
+}
+
+// loginBackoff is the wait after the given number of failures: nothing for the first free ones, then base doubling
+// with every further failure, capped at limit. A zero base turns backoff off
+func loginBackoff(failures, free int, base, limit time.Duration) time.Duration {
+	if base <= 0 || failures <= free {
+		return 0
+	}
+
+	exp := failures - free - 1
+	if exp >= 30 {
+		return limit
+	}
+
+	delay := base * time.Duration(1<<exp)
+	if delay <= 0 || delay > limit {
+		return limit
+	}
+
+	return delay
+}
+
+func (app *application) sendLockoutNotice(user *store.User, failures int, ip string) {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		Failures  int
+		IP        string
+		LockedFor string
+	}{
+		Username:  user.Username,
+		Failures:  failures,
+		IP:        ip,
+		LockedFor: app.config.auth.login.lockoutDuration.String(),
+	}
+
+	// In the background so the response takes as long as for an unknown email
+	go func() {
+		status, err := app.mailer.Send(mailer.LoginLockoutTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("failed to send lockout notice", "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status, "email", user.Email)
+	}()
+}
+
+// loginLockedResponse refuses a login while a backoff or lockout is in force, saying when to retry in whole seconds
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, lockedFor time.Duration) {
+	retryAfter := int64(math.Ceil(lockedFor.Seconds()))
+	app.rateLimitExceededResponse(w, r, strconv.FormatInt(retryAfter, 10))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			login: loginConfig{
+				freeAttempts:     2,
+				ipFreeAttempts:   5,
+				lockoutThreshold: 3,
+				backoffBase:      time.Minute,
+				lockoutDuration:  time.Minute * 15,
+				failureWindow:    time.Hour,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	login := func(email, ip string) *http.Response {
+		body := fmt.Sprintf(`{"email":%q,"password":"wrong-password"}`, email)
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.RemoteAddr = ip + ":1234"
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should lock the account at the threshold", func(t *testing.T) {
+		for i := 0; i < cfg.auth.login.lockoutThreshold; i++ {
+			checkResponseCode(t, http.StatusUnauthorized, login("ada@example.com", "198.51.100.1").StatusCode)
+		}
+
+		// From another IP too, since the lock is on the account
+		res := login("ada@example.com", "198.51.100.2")
+		checkResponseCode(t, http.StatusTooManyRequests, res.StatusCode)
+
+		if retryAfter := res.Header.Get("Retry-After"); retryAfter != "900" {
+			t.Errorf("expected Retry-After of 900 seconds, got %q", retryAfter)
+		}
+	})
+
+	t.Run("should back off an IP trying many accounts", func(t *testing.T) {
+		for i := 0; i < cfg.auth.login.ipFreeAttempts; i++ {
+			email := fmt.Sprintf("user%d@example.com", i)
+			checkResponseCode(t, http.StatusUnauthorized, login(email, "198.51.100.3").StatusCode)
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, login("next@example.com", "198.51.100.3").StatusCode)
+		checkResponseCode(t, http.StatusTooManyRequests, login("another@example.com", "198.51.100.3").StatusCode)
+	})
+
+	t.Run("should count wrong two-factor codes against the account", func(t *testing.T) {
+		for i := 0; i <= cfg.auth.login.lockoutThreshold; i++ {
+			body := `{"challenge_token":"challenge","code":"000000"}`
+			req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token/two-factor", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.RemoteAddr = fmt.Sprintf("198.51.100.%d:1234", 10+i)
+			want := http.StatusUnauthorized
+			if i == cfg.auth.login.lockoutThreshold {
+				want = http.StatusTooManyRequests
+			}
+			checkResponseCode(t, want, executeRequest(req, mux).Code)
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, login("grace@example.com", "198.51.100.20").StatusCode)
+	})
+}
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 7, want: 8 * time.Second},
+		{failures: 20, want: time.Minute},
+		{failures: 100, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures, 3, time.Second, time.Minute); got != tt.want {
+			t.Errorf("loginBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the access token used for the request and the refresh token issued with it
+//	@Tags			Authentication
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	claims := getClaimsFromContext(r)
+	ctx := r.Context()
+
+	if jti, _ := claims["jti"].(string); jti != "" {
+		exp, err := claims.GetExpirationTime()
+		if err != nil || exp == nil {
+			app.unauthorizedErrorResponse(w, r, err)
+			return
+		}
+
+		if err := app.revokeToken(ctx, jti, user.ID, exp.Time); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if err := app.store.RefreshTokens.RevokeFamily(ctx, user.ID, sid); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler godoc
+//
+//	@Summary		Logs out everywhere
+//	@Description	Revokes every access and refresh token issued to the user, on all devices
+//	@Tags			Authentication
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout/all [post]
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.RevokeTokens(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// The cached user still carries the old generation
+	app.invalidateUser(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeToken denylists an access token until it expires, in Redis when it is enabled and in Postgres otherwise
+func (app *application) revokeToken(ctx context.Context, jti string, userID int64, expiry time.Time) error {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.Revoke(ctx, jti, userID, expiry)
+	}
+
+	return app.cacheStorage.RevokedTokens.Revoke(ctx, jti, time.Until(expiry))
+}
+
+func (app *application) isTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.IsRevoked(ctx, jti)
+	}
+
+	return app.cacheStorage.RevokedTokens.IsRevoked(ctx, jti)
+}
+
+func getClaimsFromContext(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestLogout(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp: time.Minute * 15,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, _, err := app.issueToken(&store.User{ID: 1}, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logout := func() int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should revoke the token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, logout())
+	})
+
+	t.Run("should reject a revoked token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, logout())
+	})
+}
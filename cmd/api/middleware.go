@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/u-iDaniel/go-social-app/internal/store"
 )
@@ -28,6 +30,26 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		token := parts[1]
 
+		if strings.HasPrefix(token, accessTokenPrefix) {
+			user, at, err := app.authenticateAccessToken(r.Context(), token)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			// Routes that declared no scope are closed to personal access tokens
+			if !checkScope(r, at) {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userCtx, user)
+			ctx = context.WithValue(ctx, accessTokenCtx, at)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil || !jwtToken.Valid {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -44,13 +66,48 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
+		// Tokens issued before token IDs were added have no jti and can only be revoked by generation
+		if jti, _ := claims["jti"].(string); jti != "" {
+			revoked, err := app.isTokenRevoked(ctx, jti)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if revoked {
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("token has been revoked"))
+				return
+			}
+		}
+
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
+		// Tokens from before a password reset carry an older generation. A missing claim counts as generation 0
+		gen, _ := claims["gen"].(float64)
+		if int(gen) != user.TokenGeneration {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token has been revoked"))
+			return
+		}
+
+		// Tokens from before sessions were recorded have no sid
+		if sid, _ := claims["sid"].(string); sid != "" {
+			if err := app.store.Sessions.Touch(ctx, sid); err != nil {
+				switch err {
+				case store.ErrNotFound:
+					app.unauthorizedErrorResponse(w, r, fmt.Errorf("session has been revoked"))
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -116,6 +173,26 @@ func (app *application) checkPostOwnership(bypassRole string, next http.HandlerF
 	})
 }
 
+// requireRole lets through users with roleName or a role above it
+func (app *application) requireRole(roleName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromContext(r), roleName)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	bypassRole, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -155,6 +232,17 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+// invalidateUser drops the cached copy of a user after a write so the next lookup reads the database
+func (app *application) invalidateUser(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Users.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("failed to invalidate cached user", "userID", userID, "error", err.Error())
+	}
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
@@ -166,3 +254,20 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// TimeoutMiddleware cancels requests that run longer than timeout. Event streams are long-lived by design and
+// manage their own lifetime, so they are exempt
+func (app *application) TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withTimeout := middleware.Timeout(timeout)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			withTimeout.ServeHTTP(w, r)
+		})
+	}
+}
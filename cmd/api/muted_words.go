@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type MutedWordPayload struct {
+	Kind      string     `json:"kind" validate:"required,oneof=word phrase tag"`
+	Value     string     `json:"value" validate:"required,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"` // omit or null to mute until removed
+}
+
+func (p MutedWordPayload) validate() error {
+	if err := Validate.Struct(p); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(p.Value) == "" {
+		return errors.New("value must not be blank")
+	}
+
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+
+	return nil
+}
+
+// GetMutedWords godoc
+//
+//	@Summary		Lists muted words
+//	@Description	Lists the authenticated user's active muted words, phrases and tags
+//	@Tags			mutes
+//	@Produce		json
+//	@Success		200	{object}	[]store.MutedWord
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words [get]
+func (app *application) getMutedWordsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	words, err := app.store.MutedWords.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, words); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// CreateMutedWord godoc
+//
+//	@Summary		Mutes a word, phrase or tag
+//	@Description	Hides matching posts from the feed and search, optionally until expires_at
+//	@Tags			mutes
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MutedWordPayload	true	"Muted word"
+//	@Success		201		{object}	store.MutedWord
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Already muted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words [post]
+func (app *application) createMutedWordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MutedWordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	mw := &store.MutedWord{
+		UserID:    user.ID,
+		Kind:      payload.Kind,
+		Value:     normalizeMutedValue(payload.Kind, payload.Value),
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	if err := app.store.MutedWords.Create(r.Context(), mw); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, mw); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateMutedWord godoc
+//
+//	@Summary		Updates a muted word
+//	@Description	Replaces the kind, value and expiry of a muted word
+//	@Tags			mutes
+//	@Accept			json
+//	@Produce		json
+//	@Param			mutedWordID	path		int					true	"Muted word ID"
+//	@Param			payload		body		MutedWordPayload	true	"Muted word"
+//	@Success		200			{object}	store.MutedWord
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words/{mutedWordID} [put]
+func (app *application) updateMutedWordHandler(w http.ResponseWriter, r *http.Request) {
+	mutedWordID, err := strconv.ParseInt(chi.URLParam(r, "mutedWordID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload MutedWordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	mw := &store.MutedWord{
+		ID:        mutedWordID,
+		UserID:    user.ID,
+		Kind:      payload.Kind,
+		Value:     normalizeMutedValue(payload.Kind, payload.Value),
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	if err := app.store.MutedWords.Update(r.Context(), mw); err != nil {
+		app.mutedWordErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, mw); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteMutedWord godoc
+//
+//	@Summary		Unmutes a word
+//	@Description	Removes a muted word, phrase or tag
+//	@Tags			mutes
+//	@Param			mutedWordID	path	int	true	"Muted word ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words/{mutedWordID} [delete]
+func (app *application) deleteMutedWordHandler(w http.ResponseWriter, r *http.Request) {
+	mutedWordID, err := strconv.ParseInt(chi.URLParam(r, "mutedWordID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.MutedWords.Delete(r.Context(), user.ID, mutedWordID); err != nil {
+		app.mutedWordErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) mutedWordErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		app.notFoundResponse(w, r, err)
+	case errors.Is(err, store.ErrConflict):
+		app.conflictResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// normalizeMutedValue trims the value and drops a leading # from tags
+func normalizeMutedValue(kind, value string) string {
+	value = strings.TrimSpace(value)
+	if kind == "tag" {
+		value = strings.TrimPrefix(value, "#")
+	}
+
+	return value
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestCreateMutedWord(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should mute a word", `{"kind":"word","value":"spoilers"}`, http.StatusCreated},
+		{"should mute a tag until it expires", `{"kind":"tag","value":"#go","expires_at":"` + future + `"}`, http.StatusCreated},
+		{"should require a kind", `{"value":"spoilers"}`, http.StatusBadRequest},
+		{"should reject an unknown kind", `{"kind":"user","value":"ada"}`, http.StatusBadRequest},
+		{"should reject a blank value", `{"kind":"word","value":"   "}`, http.StatusBadRequest},
+		{"should reject a value over 100 characters", `{"kind":"phrase","value":"` + strings.Repeat("a", 101) + `"}`, http.StatusBadRequest},
+		{"should reject an expiry in the past", `{"kind":"word","value":"later","expires_at":"` + past + `"}`, http.StatusBadRequest},
+		{"should reject unknown fields", `{"kind":"word","value":"later","user_id":2}`, http.StatusBadRequest},
+		{"should not mute the same word twice", `{"kind":"word","value":" spoilers "}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/v1/users/me/muted-words", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestManageMutedWords(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Result()
+	}
+
+	list := func() []store.MutedWord {
+		res := request(http.MethodGet, "/v1/users/me/muted-words", "")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.MutedWord `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	checkResponseCode(t, http.StatusCreated, request(http.MethodPost, "/v1/users/me/muted-words", `{"kind":"tag","value":" #Go "}`).StatusCode)
+	checkResponseCode(t, http.StatusCreated, request(http.MethodPost, "/v1/users/me/muted-words", `{"kind":"word","value":"spoilers"}`).StatusCode)
+
+	t.Run("should list muted words newest first", func(t *testing.T) {
+		words := list()
+		if len(words) != 2 || words[0].Value != "spoilers" {
+			t.Fatalf("unexpected muted words %+v", words)
+		}
+
+		if words[1].Kind != "tag" || words[1].Value != "Go" {
+			t.Errorf("expected the tag to be stored without # and spaces, got %q", words[1].Value)
+		}
+	})
+
+	t.Run("should update a muted word", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+		checkResponseCode(t, http.StatusOK, request(http.MethodPut, "/v1/users/me/muted-words/2", `{"kind":"phrase","value":"the ending","expires_at":"`+expiresAt+`"}`).StatusCode)
+
+		words := list()
+		if len(words) != 2 || words[0].Kind != "phrase" || words[0].ExpiresAt == nil {
+			t.Errorf("expected the word to become an expiring phrase, got %+v", words)
+		}
+	})
+
+	t.Run("should validate updates like new muted words", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodPut, "/v1/users/me/muted-words/2", `{"kind":"word","value":"x","expires_at":"`+past+`"}`).StatusCode)
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodPut, "/v1/users/me/muted-words/2", `{"kind":"emoji","value":"x"}`).StatusCode)
+	})
+
+	t.Run("should not update a muted word into a duplicate", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, request(http.MethodPut, "/v1/users/me/muted-words/2", `{"kind":"tag","value":"go"}`).StatusCode)
+	})
+
+	t.Run("should not update a muted word that doesn't exist", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(http.MethodPut, "/v1/users/me/muted-words/99", `{"kind":"word","value":"x"}`).StatusCode)
+	})
+
+	t.Run("should delete a muted word", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(http.MethodDelete, "/v1/users/me/muted-words/1", "").StatusCode)
+
+		if words := list(); len(words) != 1 || words[0].ID != 2 {
+			t.Errorf("expected only the phrase to be left, got %+v", words)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(http.MethodDelete, "/v1/users/me/muted-words/1", "").StatusCode)
+	})
+
+	t.Run("should reject an invalid muted word ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodDelete, "/v1/users/me/muted-words/abc", "").StatusCode)
+	})
+}
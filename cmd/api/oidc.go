@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/oidc"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type OIDCCallbackPayload struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=100"`
+}
+
+// OIDCLink is where to send the user to confirm linking an external identity
+type OIDCLink struct {
+	AuthURL string `json:"auth_url"`
+}
+
+// oidcStateCookie binds a login to the browser that started it so a callback can't be replayed in someone else's
+const oidcStateCookie = "oidc_state"
+
+var (
+	errOIDCEmailRequired = errors.New("the provider did not share a verified email address")
+	errOIDCAccountExists = errors.New("an account with this email already exists; log in and link the provider from your account")
+)
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts an external login
+//	@Description	Redirects to the OpenID provider's login page. The provider sends the user back to the configured redirect URL with a code and state, which are passed to the callback endpoint from the same browser
+//	@Tags			Authentication
+//	@Param			provider	path	string	true	"Provider name"
+//	@Success		302
+//	@Failure		404	{object}	error	"Unknown provider"
+//	@Failure		500	{object}	error
+//	@Router			/authentication/oidc/{provider}/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	authURL, ok := app.startOIDCFlow(w, r, 0)
+	if !ok {
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// linkIdentityHandler godoc
+//
+//	@Summary		Links an external login
+//	@Description	Starts linking an OpenID provider account to the authenticated user. Send the user to auth_url from the same browser; the callback endpoint then links the identity instead of logging in
+//	@Tags			users
+//	@Produce		json
+//	@Param			provider	path		string		true	"Provider name"
+//	@Success		200			{object}	OIDCLink
+//	@Failure		404			{object}	error	"Unknown provider"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/identities/{provider} [post]
+func (app *application) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	authURL, ok := app.startOIDCFlow(w, r, user.ID)
+	if !ok {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, OIDCLink{AuthURL: authURL}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// startOIDCFlow stores a new state for the provider in the route and sets the cookie that ties it to this browser.
+// A non-zero userID makes the flow link an identity to that user. It writes the error response itself and returns
+// false on failure
+func (app *application) startOIDCFlow(w http.ResponseWriter, r *http.Request, userID int64) (string, bool) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := app.oidcProviders[name]
+	if !ok {
+		app.notFoundResponse(w, r, fmt.Errorf("unknown OIDC provider %q", name))
+		return "", false
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return "", false
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	ctx := r.Context()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return "", false
+	}
+
+	hash := sha256.Sum256([]byte(state))
+	hashState := hex.EncodeToString(hash[:])
+
+	st := &store.OIDCState{Provider: name, Nonce: nonce, CodeVerifier: verifier, UserID: userID}
+	if err := app.store.Identities.CreateState(ctx, hashState, st, app.config.oidc.stateExp); err != nil {
+		app.internalServerError(w, r, err)
+		return "", false
+	}
+
+	app.setOIDCStateCookie(w, state, int(app.config.oidc.stateExp.Seconds()))
+
+	return authURL, true
+}
+
+func (app *application) setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/v1/authentication/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes an external login
+//	@Description	Exchanges the code and state the provider redirected back with for tokens, creating an account on the first login. Accounts with two-factor authentication get a challenge instead. When the flow was started to link an identity, it is linked and nothing is returned
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			payload		body		OIDCCallbackPayload	true	"Code and state"
+//	@Success		201			{object}	TokenPair			"Tokens"
+//	@Success		200			{object}	TwoFactorChallenge	"A second factor is required"
+//	@Success		204			"The identity was linked"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error	"Unknown provider"
+//	@Failure		409			{object}	error	"The email or identity belongs to another account"
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [post]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := app.oidcProviders[name]
+	if !ok {
+		app.notFoundResponse(w, r, fmt.Errorf("unknown OIDC provider %q", name))
+		return
+	}
+
+	var payload OIDCCallbackPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The state has to come back to the browser that started the flow, or someone could log a victim into their own
+	// account by sending them a callback link
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(payload.State)) != 1 {
+		app.unauthorizedErrorResponse(w, r, errors.New("OIDC state does not belong to this browser"))
+		return
+	}
+	app.setOIDCStateCookie(w, "", -1)
+
+	hash := sha256.Sum256([]byte(payload.State))
+	hashState := hex.EncodeToString(hash[:])
+
+	ctx := r.Context()
+
+	st, err := app.store.Identities.ConsumeState(ctx, hashState)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("unknown or expired OIDC state"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if st.Provider != name {
+		app.unauthorizedErrorResponse(w, r, errors.New("OIDC state belongs to another provider"))
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, payload.Code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if st.UserID != 0 {
+		if err := app.store.Identities.Link(ctx, st.UserID, name, claims.Subject, claims.Email); err != nil {
+			switch err {
+			case store.ErrConflict:
+				app.conflictResponse(w, r, errors.New("this identity is already linked to an account"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	user, err := app.store.Identities.GetUser(ctx, name, claims.Subject)
+	if err == store.ErrNotFound {
+		user, err = app.createOIDCUser(ctx, name, claims)
+	}
+	if err != nil {
+		switch err {
+		case errOIDCEmailRequired:
+			app.badRequestResponse(w, r, err)
+		case errOIDCAccountExists:
+			app.conflictResponse(w, r, err)
+		case store.ErrConflict, store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, errors.New("an account with this email exists but can't be linked"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// The provider's login doesn't replace the second factor set up here
+	if user.TwoFactorEnabled {
+		app.twoFactorChallengeResponse(w, r, user)
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// createOIDCUser handles the first login with an external identity by creating a new, already activated account.
+// An existing account with the same email is never linked here; its owner has to link the identity while logged in
+func (app *application) createOIDCUser(ctx context.Context, provider string, claims *oidc.Claims) (*store.User, error) {
+	// Creating on an unverified email would let anyone claim someone else's address
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errOIDCEmailRequired
+	}
+
+	_, err := app.store.Users.GetByEmail(ctx, claims.Email)
+	switch err {
+	case nil:
+		return nil, errOIDCAccountExists
+	case store.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	user := &store.User{
+		Email: claims.Email,
+		Role:  store.Role{Name: "user"},
+	}
+
+	// The account has no usable password until the user sets one with a password reset
+	if err := user.Password.Set(uuid.New().String()); err != nil {
+		return nil, err
+	}
+
+	for _, username := range usernameCandidates(claims) {
+		user.Username = username
+
+		err := app.store.Users.CreateFromIdentity(ctx, user, provider, claims.Subject)
+		switch err {
+		case nil:
+			return user, nil
+		case store.ErrDuplicateUsername:
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, store.ErrDuplicateUsername
+}
+
+// usernameCandidates derives usernames to try for a new account from the provider's preferred username or the email,
+// falling back to numbered variants when those are taken
+func usernameCandidates(claims *oidc.Claims) []string {
+	base := sanitizeUsername(claims.PreferredUsername)
+	if Validate.Var(base, "username") != nil {
+		base = sanitizeUsername(strings.SplitN(claims.Email, "@", 2)[0])
+	}
+	if len(base) > 25 {
+		base = base[:25]
+	}
+
+	var candidates []string
+	if Validate.Var(base, "username") == nil {
+		candidates = append(candidates, base)
+	}
+
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	for len(candidates) < 5 {
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			break
+		}
+		candidates = append(candidates, fmt.Sprintf("%s_%04d", base, n.Int64()))
+	}
+
+	return candidates
+}
+
+func sanitizeUsername(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r == '.' || r == '-' || r == ' ':
+			return '_'
+		default:
+			return -1
+		}
+	}, s)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/u-iDaniel/go-social-app/internal/oidc"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should not find unconfigured providers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/unknown/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	var discovery *httptest.Server
+	discovery = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 discovery.URL,
+			"authorization_endpoint": discovery.URL + "/authorize",
+			"token_endpoint":         discovery.URL + "/token",
+			"jwks_uri":               discovery.URL + "/jwks",
+		})
+	}))
+	defer discovery.Close()
+
+	app.oidcProviders = map[string]*oidc.Provider{
+		"sso": oidc.NewProvider(oidc.Config{IssuerURL: discovery.URL, ClientID: "client"}, discovery.Client()),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/sso/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusFound, rr.Code)
+
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+
+	t.Run("should tie the state to the browser with a cookie", func(t *testing.T) {
+		if cookie == nil || cookie.Value != state || !cookie.HttpOnly {
+			t.Fatalf("expected an HttpOnly state cookie, got %+v", cookie)
+		}
+	})
+
+	callback := func(cookieValue string) int {
+		body := `{"code":"code","state":"` + state + `"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/oidc/sso/callback", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cookieValue != "" {
+			req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookieValue})
+		}
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reject a callback without the cookie", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, callback(""))
+	})
+
+	t.Run("should reject a callback from another browser", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, callback("someone-elses-state"))
+	})
+}
+
+func TestUsernameCandidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims oidc.Claims
+		first  string
+	}{
+		{"should use the preferred username", oidc.Claims{PreferredUsername: "ada.lovelace", Email: "ada@example.com"}, "ada_lovelace"},
+		{"should fall back to the email", oidc.Claims{PreferredUsername: "a", Email: "grace-hopper@example.com"}, "grace_hopper"},
+		{"should number reserved names", oidc.Claims{Email: "admin@example.com"}, "admin_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := usernameCandidates(&tt.claims)
+			if len(candidates) != 5 {
+				t.Fatalf("expected 5 candidates, got %v", candidates)
+			}
+
+			if !strings.HasPrefix(candidates[0], tt.first) {
+				t.Errorf("expected the first candidate to start with %q, got %q", tt.first, candidates[0])
+			}
+
+			for _, c := range candidates {
+				if err := Validate.Var(c, "username"); err != nil {
+					t.Errorf("candidate %q is not a valid username", c)
+				}
+			}
+		})
+	}
+}
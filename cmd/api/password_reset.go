@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/mailer"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset email
+//	@Description	Sends a single-use reset link if the email belongs to an active account. The response is the same whether or not it does
+//	@Tags			Authentication
+//	@Accept			json
+//	@Param			payload	body	ForgotPasswordPayload	true	"Account email"
+//	@Success		202
+//	@Failure		400	{object}	error
+//	@Failure		429	{object}	error	"Too many reset emails for this address"
+//	@Router			/authentication/password-reset [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Limited per address whether or not it has an account, so the limit doesn't reveal anything either
+	if allow, retryAfter := app.passwordResetLimiter.Allow(strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	// Failures are only logged: answering differently would reveal whether the address has an account
+	if err := app.sendPasswordReset(r, payload.Email); err != nil {
+		app.logger.Errorw("failed to start password reset", "error", err.Error())
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *application) sendPasswordReset(r *http.Request, email string) error {
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken, app.config.mail.resetExp); err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	// Sending retries with backoff, so do it in the background to keep the response time the same for unknown emails
+	go func() {
+		status, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("failed to send password reset email", "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status, "email", user.Email)
+	}()
+
+	return nil
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using the token from a reset email. Every existing token for the account is revoked
+//	@Tags			Authentication
+//	@Accept			json
+//	@Param			payload	body	ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"Invalid or expired token"
+//	@Failure		500	{object}	error
+//	@Router			/authentication/password-reset/confirm [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.ResetPassword(ctx, payload.Token, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	// Proving access to the inbox is enough to lift a lockout
+	if err := app.store.LoginFailures.Reset(ctx, newLoginKeys(r, user.Email).account); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/ratelimiter"
+)
+
+func TestForgotPassword(t *testing.T) {
+	cfg := config{
+		mail: mailConfig{resetExp: time.Hour},
+		accounts: accountsConfig{
+			passwordResetLimit: ratelimiter.Config{
+				RequestsPerTimeFrame: 1,
+				TimeFrame:            time.Minute,
+				MaxClients:           10,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	forgot := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/password-reset", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should require a valid email", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, forgot(`{"email":"not-an-email"}`))
+	})
+
+	t.Run("should answer known and unknown emails alike", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, forgot(`{"email":"ada@example.com"}`))
+		checkResponseCode(t, http.StatusAccepted, forgot(`{"email":"nobody@example.invalid"}`))
+	})
+
+	t.Run("should limit reset emails per address", func(t *testing.T) {
+		checkResponseCode(t, http.StatusTooManyRequests, forgot(`{"email":"ADA@example.com"}`))
+		checkResponseCode(t, http.StatusTooManyRequests, forgot(`{"email":"nobody@example.invalid"}`))
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createReset := func(plainToken string, exp time.Duration) {
+		hash := sha256.Sum256([]byte(plainToken))
+		err := app.store.Users.CreatePasswordReset(context.Background(), 1, hex.EncodeToString(hash[:]), exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reset := func(token string) int {
+		body := `{"token":"` + token + `","password":"new password"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/password-reset/confirm", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	createReset("valid-token", time.Hour)
+	createReset("expired-token", -time.Minute)
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, reset("expired-token"))
+	})
+
+	t.Run("should reset the password", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, reset("valid-token"))
+	})
+
+	t.Run("should reject a token that was already used", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, reset("valid-token"))
+	})
+
+	t.Run("should revoke tokens issued before the reset", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/deactivate", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
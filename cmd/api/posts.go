@@ -5,8 +5,11 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/events"
 	"github.com/u-iDaniel/go-social-app/internal/store"
 )
 
@@ -19,6 +22,7 @@ type CreatePostPayload struct {
 type UpdatePostPayload struct {
 	Title   *string `json:"title" validate:"omitempty,max=100"`
 	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Version *int    `json:"version" validate:"omitempty,gte=0"` // optional, the If-Match header can be sent instead
 }
 
 type postKey string
@@ -34,6 +38,7 @@ const postCtx postKey = "post"
 //	@Produce		json
 //	@Param			id	path		int	true	"Post ID"
 //	@Success		200	{object}	store.Post
+//	@Success		304
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
@@ -41,7 +46,11 @@ const postCtx postKey = "post"
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	if !app.canViewPost(w, r, post) {
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, getUserFromContext(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -49,6 +58,11 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post.Comments = comments
 
+	// Comments have no updated_at and can be deleted, so there is no Last-Modified that would catch every change
+	if checkNotModified(w, r, postETag(post), time.Time{}) {
+		return
+	}
+
 	// Return response
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -98,6 +112,12 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, user.ID)
+	if err != nil {
+		app.logger.Errorw("failed to load followers for feed event", "error", err.Error())
+	}
+	app.publishEvent(ctx, events.TypeFeedPost, user.ID, followerIDs, post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -146,13 +166,16 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
+//	@Param			If-Match	header		string				false	"ETag from a previous GET, alternative to sending version"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -169,6 +192,20 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	ctx := r.Context()
+
+	// If-Match lets clients use the ETag from a previous GET for optimistic locking instead of the version. Only
+	// the post's part of the tag is compared so new comments don't fail the update
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !postVersionMatches(ifMatch, post) {
+		app.preconditionFailedResponse(w, r, errors.New("the post has been modified since it was fetched"))
+		return
+	}
+
+	if payload.Version != nil {
+		post.Version = *payload.Version
+	}
+
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
@@ -177,7 +214,7 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
+	if err := app.store.Posts.Update(ctx, post); err != nil {
 		switch {
 		case errors.Is(err, store.ErrConflict):
 			app.conflictResponse(w, r, err)
@@ -187,12 +224,75 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if ifMatch != "" {
+		comments, err := app.store.Comments.GetByPostID(ctx, post.ID, getUserFromContext(r).ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		post.Comments = comments
+		w.Header().Set("ETag", postETag(post))
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
+// postETag tags a post together with its comments. The post's own version comes first so If-Match can check it on
+// its own
+func postETag(post *store.Post) string {
+	commentParts := make([]any, 0, len(post.Comments))
+	for _, c := range post.Comments {
+		commentParts = append(commentParts, c.ID, c.Content)
+	}
+
+	return `W/"` + etagHash(post.ID, post.Version) + "-" + etagHash(commentParts...) + `"`
+}
+
+// postVersionMatches compares the post part of the entity tags in an If-Match header against the post's version
+func postVersionMatches(header string, post *store.Post) bool {
+	version := etagHash(post.ID, post.Version)
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.Trim(strings.TrimPrefix(strings.TrimSpace(candidate), "W/"), `"`)
+		if candidate == "*" {
+			return true
+		}
+
+		if tag, _, _ := strings.Cut(candidate, "-"); tag == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canViewPost reports whether the authenticated user may see a post. Posts by private accounts are only visible to
+// the author and approved followers, and blocks hide posts in both directions. Everyone else gets a 404 so the
+// post's existence isn't revealed. Posts by deactivated authors never get this far: the store doesn't find them
+func (app *application) canViewPost(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	viewer := getUserFromContext(r)
+	if post.UserID == viewer.ID {
+		return true
+	}
+
+	rel, err := app.store.Followers.GetRelationship(r.Context(), viewer.ID, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if rel.Blocking || rel.BlockedBy || (post.User.IsPrivate && !rel.Following) {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return false
+	}
+
+	return true
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// UpdateProfilePayload holds the profile fields to change; omitted fields are left as they are and an empty string
+// clears a field
+type UpdateProfilePayload struct {
+	DisplayName *string   `json:"display_name" validate:"omitnil,max=50"`
+	Bio         *string   `json:"bio" validate:"omitnil,max=280"`
+	AvatarURL   *string   `json:"avatar_url" validate:"omitnil,max=500"`
+	Location    *string   `json:"location" validate:"omitnil,max=100"`
+	Links       *[]string `json:"links" validate:"omitnil,max=5,dive,http_url,max=200"`
+}
+
+func (p UpdateProfilePayload) validate() error {
+	if err := Validate.Struct(p); err != nil {
+		return err
+	}
+
+	if p.AvatarURL != nil && *p.AvatarURL != "" {
+		return Validate.Var(*p.AvatarURL, "http_url")
+	}
+
+	return nil
+}
+
+// UpdateProfile godoc
+//
+//	@Summary		Updates the authenticated user's profile
+//	@Description	Updates display name, bio, avatar URL, location and links. Omitted fields are unchanged
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if payload.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*payload.DisplayName)
+	}
+	if payload.Bio != nil {
+		user.Bio = strings.TrimSpace(*payload.Bio)
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.Location != nil {
+		user.Location = strings.TrimSpace(*payload.Location)
+	}
+	if payload.Links != nil {
+		user.Links = *payload.Links
+	}
+	if user.Links == nil {
+		user.Links = []string{}
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
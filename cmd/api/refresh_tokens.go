@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=100"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and refresh token. Each refresh token works once; presenting a spent one revokes every token rotated from the same login
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	TokenPair			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(payload.RefreshToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	plainRefresh := uuid.New().String()
+	hash = sha256.Sum256([]byte(plainRefresh))
+	hashRefresh := hex.EncodeToString(hash[:])
+
+	ctx := r.Context()
+
+	rt, err := app.store.RefreshTokens.Rotate(ctx, hashToken, hashRefresh, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reused, token family revoked", "ip", r.RemoteAddr)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Rotate already refused deactivated accounts, but the account can still go away before it is loaded here
+	user, err := app.getUser(ctx, rt.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token, jti, err := app.issueToken(user, rt.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.saveSession(r, user.ID, rt.FamilyID, jti); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := TokenPair{
+		Token:        token,
+		RefreshToken: plainRefresh,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
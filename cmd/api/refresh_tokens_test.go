@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshToken(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	refresh := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should require a refresh token", func(t *testing.T) {
+		res := refresh(`{}`)
+		checkResponseCode(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		res := refresh(`{"refresh_token":"old-token"}`)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var envelope struct {
+			Data TokenPair `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+
+		if envelope.Data.RefreshToken == "" || envelope.Data.RefreshToken == "old-token" {
+			t.Errorf("expected a new refresh token, got %q", envelope.Data.RefreshToken)
+		}
+		if envelope.Data.ExpiresIn != 900 {
+			t.Errorf("expected expires_in 900, got %d", envelope.Data.ExpiresIn)
+		}
+	})
+}
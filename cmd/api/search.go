@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// searchHandler godoc
+//
+//	@Summary		Full-text search
+//	@Description	Searches posts, comments and users. Supports "quoted phrases", prefix* matches, -negation and OR
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Search terms"
+//	@Param			type	query		string	false	"all, posts, comments or users"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.SearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SearchQuery{
+		Type:   "all",
+		Limit:  20,
+		Offset: 0,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if store.ToTSQuery(sq.Query) == "" {
+		app.badRequestResponse(w, r, errors.New("search query has no searchable terms"))
+		return
+	}
+
+	viewer := getUserFromContext(r)
+
+	results, err := app.store.Search.Search(r.Context(), viewer.ID, sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
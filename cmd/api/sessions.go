@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// Limits of the sessions columns. Both values come from request headers
+const (
+	maxUserAgentLength = 255
+	maxIPLength        = 45
+)
+
+// GetSessions godoc
+//
+//	@Summary		Lists sessions
+//	@Description	Lists the devices the authenticated user is logged in on, with the session making the request marked as current
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Session
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	sid, _ := getClaimsFromContext(r)["sid"].(string)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sid
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revokes a session
+//	@Description	Logs one device out: its access and refresh tokens stop working immediately
+//	@Tags			users
+//	@Param			sessionID	path	string	true	"Session ID"
+//	@Success		204
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if _, err := uuid.Parse(sessionID); err != nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	sess, err := app.store.Sessions.Revoke(ctx, user.ID, sessionID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// The session check already rejects its tokens; its latest access token is denylisted too, as on logout
+	if sess.JTI != "" {
+		if err := app.revokeToken(ctx, sess.JTI, user.ID, time.Now().Add(app.config.auth.token.exp)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeUserSessions godoc
+//
+//	@Summary		Revokes all sessions of a user
+//	@Description	Logs the user out on every device by revoking all of their tokens. Admins only
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/sessions [delete]
+func (app *application) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.RevokeTokens(ctx, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	app.logger.Infow("revoked all sessions", "userID", userID, "adminID", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveSession records the device a token was issued to, on login and again on every refresh
+func (app *application) saveSession(r *http.Request, userID int64, sessionID, jti string) error {
+	sess := &store.Session{
+		ID:        sessionID,
+		UserID:    userID,
+		JTI:       jti,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IP:        truncate(clientIP(r), maxIPLength),
+	}
+
+	return app.store.Sessions.Save(r.Context(), sess, app.config.auth.token.refreshExp)
+}
+
+// clientIP is the request's address without the port. RealIP has already applied any forwarding headers
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// truncate cuts s to at most n bytes and drops invalid UTF-8, which Postgres would reject
+func truncate(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+
+	return strings.ToValidUTF8(s, "")
+}
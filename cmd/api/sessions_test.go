@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestSessions(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour,
+			},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	login := httptest.NewRequest(http.MethodPost, "/v1/authentication/token", nil)
+	login.Header.Set("User-Agent", "test-device")
+	login.RemoteAddr = "203.0.113.7:51234"
+
+	tokens, err := app.issueTokens(login, &store.User{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		return executeRequest(req, mux)
+	}
+
+	var sessions struct {
+		Data []store.Session `json:"data"`
+	}
+
+	t.Run("should list the session with its device", func(t *testing.T) {
+		rr := request(http.MethodGet, "/v1/users/me/sessions")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if err := json.NewDecoder(rr.Body).Decode(&sessions); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sessions.Data) != 1 {
+			t.Fatalf("expected 1 session, got %d", len(sessions.Data))
+		}
+
+		sess := sessions.Data[0]
+		if !sess.Current || sess.UserAgent != "test-device" || sess.IP != "203.0.113.7" {
+			t.Errorf("unexpected session %+v", sess)
+		}
+	})
+
+	t.Run("should not find an unknown session", func(t *testing.T) {
+		rr := request(http.MethodDelete, "/v1/users/me/sessions/not-a-session")
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should revoke the session and its token", func(t *testing.T) {
+		rr := request(http.MethodDelete, "/v1/users/me/sessions/"+sessions.Data[0].ID)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = request(http.MethodGet, "/v1/users/me/sessions")
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
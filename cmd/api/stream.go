@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/events"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+type streamTicketKey string
+
+const streamTicketCtx streamTicketKey = "streamTicket"
+
+// StreamTicket lets a client that can't send an Authorization header, like a browser's EventSource, open the
+// stream with ?ticket=. The ticket stays valid while its stream is open and for a short while after, so the
+// EventSource can reconnect to the same URL
+type StreamTicket struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// StreamEvents godoc
+//
+//	@Summary		Streams real-time events
+//	@Description	Server-Sent Events stream of new feed posts, comments on the user's posts and new followers. Send Last-Event-ID to resume after a reconnect. Browsers authenticate with a ticket from /stream/ticket instead of the Authorization header
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header	string	false	"ID of the last event received"
+//	@Param			ticket			query	string	false	"Ticket from /stream/ticket"
+//	@Success		200
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	rc := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource can't set headers on the first connection, so allow a query parameter too
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		lastID = id
+	}
+
+	sub := app.broker.Subscribe(user.ID, lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// write extends the server's write deadline each time so the stream can outlive WriteTimeout
+	write := func(format string, args ...any) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(2 * app.config.stream.heartbeat)); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	if err := write("retry: %d\n\n", (5 * time.Second).Milliseconds()); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// End the stream once the user logs out or the session is revoked
+			if err := app.checkStreamAuth(r); err != nil {
+				return
+			}
+
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Broker shut down or we fell behind; the client reconnects with its Last-Event-ID
+				return
+			}
+
+			if err := write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// CreateStreamTicket godoc
+//
+//	@Summary		Creates a stream ticket
+//	@Description	Returns a ticket for opening /stream with ?ticket=, for clients like EventSource that can't send an Authorization header. It must be used within 30 seconds, then stays valid while the stream is open and for 30 seconds after so the EventSource can reconnect. Logging out ends the stream and the ticket
+//	@Tags			stream
+//	@Produce		json
+//	@Success		201	{object}	StreamTicket
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream/ticket [post]
+func (app *application) createStreamTicketHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	plainTicket := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainTicket))
+	hashTicket := hex.EncodeToString(hash[:])
+
+	// Tickets from personal access tokens have no session
+	sid, _ := getClaimsFromContext(r)["sid"].(string)
+
+	exp := app.config.stream.ticketExp
+	if err := app.store.StreamTickets.Create(r.Context(), user.ID, sid, hashTicket, exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ticket := StreamTicket{Ticket: plainTicket, ExpiresIn: int64(exp.Seconds())}
+	if err := app.jsonResponse(w, http.StatusCreated, ticket); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// streamAuthMiddleware accepts a stream ticket in place of the Authorization header
+func (app *application) streamAuthMiddleware(next http.Handler) http.Handler {
+	withToken := app.AuthTokenMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" || r.Header.Get("Authorization") != "" {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+
+		hash := sha256.Sum256([]byte(ticket))
+		hashTicket := hex.EncodeToString(hash[:])
+
+		ctx := r.Context()
+
+		userID, err := app.useStreamTicket(ctx, hashTicket)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid or expired stream ticket"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		user, err := app.getUser(ctx, userID)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, streamTicketCtx, hashTicket)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// useStreamTicket keeps the ticket alive until the next heartbeat plus the time an EventSource gets to reconnect
+func (app *application) useStreamTicket(ctx context.Context, hashTicket string) (int64, error) {
+	return app.store.StreamTickets.Use(ctx, hashTicket, app.config.stream.heartbeat+app.config.stream.ticketExp)
+}
+
+// checkStreamAuth re-checks what the stream was opened with. Tickets end with their session, and tokens with logout
+// or the session being revoked
+func (app *application) checkStreamAuth(r *http.Request) error {
+	ctx := r.Context()
+
+	if hashTicket, ok := ctx.Value(streamTicketCtx).(string); ok {
+		_, err := app.useStreamTicket(ctx, hashTicket)
+		return err
+	}
+
+	claims := getClaimsFromContext(r)
+
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := app.isTokenRevoked(ctx, jti)
+		if err != nil {
+			return err
+		}
+
+		if revoked {
+			return fmt.Errorf("token has been revoked")
+		}
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		return app.store.Sessions.Touch(ctx, sid)
+	}
+
+	return nil
+}
+
+// publishEvent sends an event to the stream. Failing to notify must never fail the request that caused it
+func (app *application) publishEvent(ctx context.Context, eventType string, actorID int64, recipients []int64, data any) {
+	if len(recipients) == 0 {
+		return
+	}
+
+	// Users who muted the actor don't get notified, and the actor is never told
+	recipients, err := app.store.UserMutes.ExcludeMuters(ctx, actorID, recipients)
+	if err != nil {
+		app.logger.Errorw("failed to filter event recipients", "type", eventType, "error", err.Error())
+		return
+	}
+
+	if len(recipients) == 0 {
+		return
+	}
+
+	e, err := events.New(eventType, actorID, recipients, data)
+	if err == nil {
+		err = app.broker.Publish(ctx, e)
+	}
+
+	if err != nil {
+		app.logger.Errorw("failed to publish event", "type", eventType, "error", err.Error())
+	}
+}
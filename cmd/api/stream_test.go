@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStreamTicket(t *testing.T) {
+	cfg := config{
+		stream: streamConfig{
+			heartbeat: time.Second,
+			ticketExp: time.Second * 30,
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/stream/ticket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data StreamTicket `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	openStream := func(ticket string) int {
+		req, err := http.NewRequest(http.MethodGet, "/v1/stream?ticket="+ticket, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The recorder can't extend write deadlines, so the stream ends right after its headers
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should open the stream with a ticket", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, openStream(res.Data.Ticket))
+	})
+
+	t.Run("should accept the ticket again for a reconnect", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, openStream(res.Data.Ticket))
+	})
+
+	t.Run("should not accept an unknown ticket", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, openStream("not-a-ticket"))
+	})
+
+	t.Run("should not accept an expired ticket", func(t *testing.T) {
+		hash := sha256.Sum256([]byte("expired-ticket"))
+		err := app.store.StreamTickets.Create(context.Background(), 1, "", hex.EncodeToString(hash[:]), -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, openStream("expired-ticket"))
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestSuggestions(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store.Suggestions.(*store.MockSuggestionStore).Set(1, []store.Suggestion{
+		{UserID: 2, Username: "ada", Score: 4.2, Reason: "followed_by_people_you_follow"},
+		{UserID: 3, Username: "grace", Score: 1.5, Reason: "shared_tags"},
+		{UserID: 4, Username: "linus", Score: 0.7, Reason: "popular"},
+	})
+
+	if err := app.store.Users.Delete(context.Background(), 99); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path string) *http.Response {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Result()
+	}
+
+	list := func(path string) []store.Suggestion {
+		res := request(http.MethodGet, path)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.Suggestion `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	t.Run("should list suggestions best first", func(t *testing.T) {
+		suggestions := list("/v1/users/me/suggestions")
+		if len(suggestions) != 3 || suggestions[0].Username != "ada" || suggestions[0].Reason != "followed_by_people_you_follow" {
+			t.Errorf("unexpected suggestions %+v", suggestions)
+		}
+	})
+
+	t.Run("should paginate suggestions", func(t *testing.T) {
+		suggestions := list("/v1/users/me/suggestions?limit=1&offset=1")
+		if len(suggestions) != 1 || suggestions[0].Username != "grace" {
+			t.Errorf("expected grace alone, got %+v", suggestions)
+		}
+	})
+
+	t.Run("should reject a limit over 50", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodGet, "/v1/users/me/suggestions?limit=51").StatusCode)
+	})
+
+	t.Run("should dismiss a suggestion", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(http.MethodDelete, "/v1/users/me/suggestions/3").StatusCode)
+
+		for _, s := range list("/v1/users/me/suggestions") {
+			if s.UserID == 3 {
+				t.Error("expected the dismissed user to be gone")
+			}
+		}
+	})
+
+	t.Run("should not dismiss an unknown user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(http.MethodDelete, "/v1/users/me/suggestions/99").StatusCode)
+	})
+
+	t.Run("should reject an invalid user ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodDelete, "/v1/users/me/suggestions/abc").StatusCode)
+	})
+}
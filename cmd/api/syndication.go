@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+	"github.com/u-iDaniel/go-social-app/internal/syndication"
+)
+
+const syndicationFeedSize = 20
+
+// UserTimelineFeed godoc
+//
+//	@Summary		Public user timeline as a feed
+//	@Description	Serves a user's public posts as RSS 2.0, Atom or JSON Feed 1.1. The format is picked from the file extension (.rss, .atom, .json) or the Accept header
+//	@Tags			feeds
+//	@Produce		application/rss+xml,application/atom+xml,application/feed+json
+//	@Param			username	path	string	true	"Username, optionally with a .rss, .atom or .json extension"
+//	@Success		200
+//	@Success		304
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/feeds/users/{username} [get]
+func (app *application) userTimelineFeedHandler(w http.ResponseWriter, r *http.Request) {
+	username, format := feedParam(r, "username")
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByUsername(ctx, username)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, err := app.store.Posts.GetPublic(ctx, store.PublicPostsFilter{Username: user.Username, Limit: syndicationFeedSize})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed := &syndication.Feed{
+		Title:       fmt.Sprintf("%s on Go Social", user.Username),
+		Description: fmt.Sprintf("Latest public posts by %s", user.Username),
+		Link:        fmt.Sprintf("%s/users/%s", app.config.frontendURL, url.PathEscape(user.Username)),
+	}
+
+	app.writeFeed(w, r, format, feed, posts)
+}
+
+// TagFeed godoc
+//
+//	@Summary		Public tag page as a feed
+//	@Description	Serves public posts with a tag as RSS 2.0, Atom or JSON Feed 1.1. The format is picked from the file extension (.rss, .atom, .json) or the Accept header
+//	@Tags			feeds
+//	@Produce		application/rss+xml,application/atom+xml,application/feed+json
+//	@Param			tag	path	string	true	"Tag, optionally with a .rss, .atom or .json extension"
+//	@Success		200
+//	@Success		304
+//	@Failure		500	{object}	error
+//	@Router			/feeds/tags/{tag} [get]
+func (app *application) tagFeedHandler(w http.ResponseWriter, r *http.Request) {
+	tag, format := feedParam(r, "tag")
+
+	posts, err := app.store.Posts.GetPublic(r.Context(), store.PublicPostsFilter{Tag: tag, Limit: syndicationFeedSize})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed := &syndication.Feed{
+		Title:       fmt.Sprintf("#%s on Go Social", tag),
+		Description: fmt.Sprintf("Latest public posts tagged %s", tag),
+		Link:        fmt.Sprintf("%s/tags/%s", app.config.frontendURL, url.PathEscape(tag)),
+	}
+
+	app.writeFeed(w, r, format, feed, posts)
+}
+
+func (app *application) writeFeed(w http.ResponseWriter, r *http.Request, format syndication.Format, feed *syndication.Feed, posts []store.Post) {
+	feed.FeedURL = app.feedURL(r)
+
+	// The ETag covers the format and every post's version so edits, new posts and posts that drop out of the feed
+	// all change it. There is no Last-Modified: a deleted post or an author going private makes nothing newer
+	etagParts := []any{format}
+	var latest time.Time
+
+	for _, p := range posts {
+		published := parseDBTime(p.CreatedAt)
+		updated := parseDBTime(p.UpdatedAt)
+		if updated.Before(published) {
+			updated = published
+		}
+
+		if updated.After(latest) {
+			latest = updated
+		}
+
+		etagParts = append(etagParts, p.ID, p.Version, p.UpdatedAt)
+
+		feed.Items = append(feed.Items, syndication.Item{
+			ID:        fmt.Sprintf("%s/posts/%d", app.config.frontendURL, p.ID),
+			Title:     p.Title,
+			Content:   p.Content,
+			Link:      fmt.Sprintf("%s/posts/%d", app.config.frontendURL, p.ID),
+			Author:    p.User.Username,
+			Tags:      p.Tags,
+			Published: published,
+			Updated:   updated,
+		})
+	}
+
+	feed.Updated = latest
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now()
+	}
+
+	w.Header().Set("Vary", "Accept")
+	if checkNotModified(w, r, weakETag(etagParts...), time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+
+	if err := format.Write(w, feed); err != nil {
+		// Headers are already sent so the error can only be logged
+		app.logger.Errorw("failed to write feed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	}
+}
+
+// feedParam reads a path parameter and splits off a known feed extension. Without one the Accept header decides
+func feedParam(r *http.Request, key string) (string, syndication.Format) {
+	value := chi.URLParam(r, key)
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+	}
+
+	ext := path.Ext(value)
+	if format, ok := syndication.FormatFromExtension(ext); ok {
+		return strings.TrimSuffix(value, ext), format
+	}
+
+	return value, syndication.Negotiate(r.Header.Get("Accept"))
+}
+
+// feedURL is the feed's own address for self links and Atom ids. It is built from EXTERNAL_URL rather than from Host
+// or X-Forwarded-Proto, which any client can set
+func (app *application) feedURL(r *http.Request) string {
+	return strings.TrimSuffix(app.externalURL().String(), "/") + r.URL.EscapedPath()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestSyndicationFeeds(t *testing.T) {
+	cfg := config{
+		apiURL:      "https://api.example.com",
+		frontendURL: "https://example.com",
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+	ctx := context.Background()
+
+	posts := []*store.Post{
+		{Title: "Ada's post", Content: "Hello", Tags: []string{"go"}, User: store.User{Username: "ada"}},
+		{Title: "Grace's post", Content: "Hi", Tags: []string{"go", "cobol"}, User: store.User{Username: "grace"}},
+	}
+	for _, p := range posts {
+		if err := app.store.Posts.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	getFeed := func(path string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		return executeRequest(req, mux).Result()
+	}
+
+	readBody := func(res *http.Response) string {
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	t.Run("should serve a user's posts in the format of the extension", func(t *testing.T) {
+		res := getFeed("/v1/feeds/users/ada.atom", nil)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+			t.Errorf("expected an Atom feed, got %q", ct)
+		}
+
+		body := readBody(res)
+		if !strings.Contains(body, "Ada&#39;s post") || strings.Contains(body, "Grace") {
+			t.Errorf("expected only ada's posts, got %s", body)
+		}
+
+		if !strings.Contains(body, "https://api.example.com/v1/feeds/users/ada.atom") {
+			t.Errorf("expected the self link to use EXTERNAL_URL, got %s", body)
+		}
+	})
+
+	t.Run("should serve a tag's posts by Accept weight", func(t *testing.T) {
+		res := getFeed("/v1/feeds/tags/cobol", map[string]string{
+			"Accept": "application/atom+xml;q=0, application/feed+json;q=0.5",
+		})
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/feed+json") {
+			t.Errorf("expected a JSON feed, got %q", ct)
+		}
+
+		body := readBody(res)
+		if !strings.Contains(body, "Grace's post") || strings.Contains(body, "Ada") {
+			t.Errorf("expected only posts tagged cobol, got %s", body)
+		}
+	})
+
+	t.Run("should answer a matching If-None-Match with 304", func(t *testing.T) {
+		res := getFeed("/v1/feeds/tags/go.rss", nil)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		if res.Header.Get("Last-Modified") != "" {
+			t.Error("feeds should not send Last-Modified")
+		}
+
+		etag := res.Header.Get("ETag")
+		res = getFeed("/v1/feeds/tags/go.rss", map[string]string{"If-None-Match": etag})
+		checkResponseCode(t, http.StatusNotModified, res.StatusCode)
+
+		// A deleted post changes the feed even though nothing in it got newer
+		if err := app.store.Posts.Delete(ctx, posts[1].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		res = getFeed("/v1/feeds/tags/go.rss", map[string]string{"If-None-Match": etag})
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+	})
+}
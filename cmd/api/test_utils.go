@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/u-iDaniel/go-social-app/internal/auth"
+	"github.com/u-iDaniel/go-social-app/internal/events"
+	"github.com/u-iDaniel/go-social-app/internal/mailer"
 	"github.com/u-iDaniel/go-social-app/internal/ratelimiter"
 	"github.com/u-iDaniel/go-social-app/internal/store"
 	"github.com/u-iDaniel/go-social-app/internal/store/cache"
@@ -30,6 +33,18 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		authenticator: testAuth,
 		config:        cfg,
 		rateLimiter:   rateLimiter,
+		activationLimiter: ratelimiter.NewBoundedFixedWindowLimiter(
+			cfg.accounts.resendActivationLimit.RequestsPerTimeFrame,
+			cfg.accounts.resendActivationLimit.TimeFrame,
+			cfg.accounts.resendActivationLimit.MaxClients,
+		),
+		passwordResetLimiter: ratelimiter.NewBoundedFixedWindowLimiter(
+			cfg.accounts.passwordResetLimit.RequestsPerTimeFrame,
+			cfg.accounts.passwordResetLimit.TimeFrame,
+			cfg.accounts.passwordResetLimit.MaxClients,
+		),
+		broker: events.NewMemoryBroker(time.Minute, 10),
+		mailer: &mailer.MockClient{},
 	}
 	return app
 }
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/u-iDaniel/go-social-app/internal/auth"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+// recoveryCodeCount is how many one-time recovery codes are handed out when 2FA is turned on
+const recoveryCodeCount = 10
+
+type EnrollTwoFactorPayload struct {
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// provisioning URI to render as a QR code
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" validate:"required,max=72"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"` // seconds
+}
+
+type TwoFactorTokenPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=100"`
+	Code           string `json:"code" validate:"required,max=20"`
+}
+
+// EnrollTwoFactor godoc
+//
+//	@Summary		Starts two-factor enrollment
+//	@Description	Requires the password. Generates a TOTP secret to add to an authenticator app; 2FA is only turned on once a code from the app is confirmed at /users/me/two-factor/enable
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		EnrollTwoFactorPayload	true	"Current password"
+//	@Success		200		{object}	TwoFactorEnrollment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Password is incorrect"
+//	@Failure		409		{object}	error	"Two-factor authentication is already on"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/two-factor [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload EnrollTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.checkCurrentPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sealed, err := app.secretBox.Seal([]byte(secret))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.Enroll(r.Context(), user.ID, sealed); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := TwoFactorEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(app.config.auth.totp.issuer, user.Email, secret),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// EnableTwoFactor godoc
+//
+//	@Summary		Turns on two-factor authentication
+//	@Description	Confirms the pending enrollment with a code from the authenticator app. Returns one-time recovery codes, which are only shown this once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"Code from the authenticator app"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error	"Invalid code"
+//	@Failure		404		{object}	error	"No pending enrollment"
+//	@Failure		409		{object}	error	"Two-factor authentication is already on"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/two-factor/enable [post]
+func (app *application) enableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if tf.EnabledAt != nil {
+		app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := app.secretBox.Open(tf.Secret)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(string(secret), strings.TrimSpace(payload.Code), time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("invalid two-factor code"))
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.Enable(ctx, user.ID, step, hashes); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DisableTwoFactor godoc
+//
+//	@Summary		Turns off two-factor authentication
+//	@Description	Requires the password and a code from the authenticator app or a recovery code
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body	DisableTwoFactorPayload	true	"Password and code"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error	"Password or code is incorrect"
+//	@Failure		429	{object}	error	"Too many failed codes; see Retry-After"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/two-factor [delete]
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DisableTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.checkCurrentPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	valid, lockedFor, err := app.verifySecondFactor(ctx, r, user, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return
+	}
+
+	if !valid {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.TwoFactor.Disable(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Replaces the recovery codes
+//	@Description	Requires a code from the authenticator app. Every earlier recovery code stops working
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"Code from the authenticator app"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Code is incorrect or two-factor authentication is off"
+//	@Failure		429		{object}	error	"Too many failed codes; see Retry-After"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/two-factor/recovery-codes [post]
+func (app *application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	// Recovery codes can't be used to mint new ones, or one leaked code would be as good as the authenticator
+	if !isTOTPCode(payload.Code) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	valid, lockedFor, err := app.verifySecondFactor(ctx, r, user, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return
+	}
+
+	if !valid {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// twoFactorTokenHandler godoc
+//
+//	@Summary		Completes a two-factor login
+//	@Description	Exchanges the challenge from /authentication/token and a code from the authenticator app, or a recovery code, for tokens. A challenge allows 5 attempts
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorTokenPayload	true	"Challenge token and code"
+//	@Success		201		{object}	TokenPair				"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed codes; see Retry-After"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/token/two-factor [post]
+func (app *application) twoFactorTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(payload.ChallengeToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx := r.Context()
+
+	user, err := app.store.TwoFactor.AttemptChallenge(ctx, hashToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	valid, lockedFor, err := app.verifySecondFactor(ctx, r, user, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return
+	}
+
+	if !valid {
+		app.unauthorizedErrorResponse(w, r, errors.New("invalid two-factor code"))
+		return
+	}
+
+	if err := app.store.TwoFactor.DeleteChallenge(ctx, hashToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// twoFactorChallengeResponse answers a correct password for an account with 2FA with a short-lived challenge
+func (app *application) twoFactorChallengeResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	exp := app.config.auth.totp.challengeExp
+	if err := app.store.TwoFactor.CreateChallenge(r.Context(), user.ID, hashToken, exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	challenge := TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    plainToken,
+		ExpiresIn:         int64(exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// verifySecondFactor checks a code like checkSecondFactor under the same throttle as password logins: nothing is
+// checked while the account or IP is locked out, and a wrong code counts as a failed login for the account
+func (app *application) verifySecondFactor(ctx context.Context, r *http.Request, user *store.User, code string) (bool, time.Duration, error) {
+	keys := newLoginKeys(r, user.Email)
+
+	lockedFor, err := app.loginLockedFor(ctx, keys)
+	if err != nil || lockedFor > 0 {
+		return false, lockedFor, err
+	}
+
+	valid, err := app.checkSecondFactor(ctx, user.ID, code)
+	if err != nil || valid {
+		return valid, 0, err
+	}
+
+	return false, 0, app.recordLoginFailure(ctx, r, keys, user)
+}
+
+// checkSecondFactor accepts either a current TOTP code that wasn't used before or an unused recovery code, which is
+// spent. It is false when the user doesn't have 2FA turned on
+func (app *application) checkSecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	tf, err := app.store.TwoFactor.Get(ctx, userID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if tf.EnabledAt == nil {
+		return false, nil
+	}
+
+	code = strings.TrimSpace(code)
+
+	if !isTOTPCode(code) {
+		hash := sha256.Sum256([]byte(auth.NormalizeRecoveryCode(code)))
+		err := app.store.TwoFactor.UseRecoveryCode(ctx, userID, hex.EncodeToString(hash[:]))
+		switch err {
+		case nil:
+			return true, nil
+		case store.ErrNotFound:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	secret, err := app.secretBox.Open(tf.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(string(secret), code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	switch err := app.store.TwoFactor.UseStep(ctx, userID, step); err {
+	case nil:
+		return true, nil
+	case store.ErrCodeReused:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func isTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != auth.TOTPDigits {
+		return false
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// generateRecoveryCodes returns the codes to show the user and the hashes to store
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash := sha256.Sum256([]byte(auth.NormalizeRecoveryCode(code)))
+		codes = append(codes, code)
+		hashes = append(hashes, hex.EncodeToString(hash[:]))
+	}
+
+	return codes, hashes, nil
+}
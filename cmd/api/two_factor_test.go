@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/u-iDaniel/go-social-app/internal/auth"
+)
+
+func TestTwoFactorToken(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should require the challenge and a code", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token/two-factor", strings.NewReader(`{"challenge_token":"abc"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d codes and %d hashes", recoveryCodeCount, len(codes), len(hashes))
+	}
+
+	for _, code := range codes {
+		if isTOTPCode(code) {
+			t.Errorf("recovery code %q could be mistaken for a TOTP code", code)
+		}
+		if got := auth.NormalizeRecoveryCode(" " + strings.ToUpper(code)); got != strings.ReplaceAll(code, "-", "") {
+			t.Errorf("expected %q to normalize, got %q", code, got)
+		}
+	}
+}
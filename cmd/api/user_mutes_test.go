@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestMuteUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	ctx := context.Background()
+
+	tokenFor := func(userID int64) string {
+		token, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"sub": userID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	request := func(userID int64, method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokenFor(userID))
+		return executeRequest(req, mux).Result()
+	}
+
+	listMuted := func() []store.UserMute {
+		res := request(1, http.MethodGet, "/v1/users/me/mutes", "")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.UserMute `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	if err := app.store.Users.Delete(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not mute yourself", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/1/mute", "").StatusCode)
+	})
+
+	t.Run("should reject an expiry in the past", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/2/mute", `{"expires_at":"`+past+`"}`).StatusCode)
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/2/mute", `{"expires_at":"soon"}`).StatusCode)
+	})
+
+	t.Run("should not mute an unknown user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodPut, "/v1/users/99/mute", "").StatusCode)
+	})
+
+	t.Run("should mute without an expiry", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/mute", "").StatusCode)
+
+		if muted := listMuted(); len(muted) != 1 || muted[0].UserID != 2 || muted[0].ExpiresAt != nil {
+			t.Errorf("expected user 2 muted until unmuted, got %+v", muted)
+		}
+	})
+
+	t.Run("should mute until an expiry", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/3/mute", `{"expires_at":"`+future+`"}`).StatusCode)
+
+		muted := listMuted()
+		if len(muted) != 2 || muted[0].UserID != 3 || muted[0].ExpiresAt == nil {
+			t.Errorf("expected user 3 muted with an expiry first, got %+v", muted)
+		}
+	})
+
+	t.Run("should replace the expiry when muting again", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/3/mute", "").StatusCode)
+
+		if muted := listMuted(); len(muted) != 2 || muted[0].UserID != 3 || muted[0].ExpiresAt != nil {
+			t.Errorf("expected user 3 muted until unmuted, got %+v", muted)
+		}
+	})
+
+	t.Run("should not notify about muted users", func(t *testing.T) {
+		// User 4's mute has already run out
+		expired := time.Now().Add(-time.Minute)
+		if err := app.store.UserMutes.Mute(ctx, 1, 4, &expired); err != nil {
+			t.Fatal(err)
+		}
+
+		sub := app.broker.Subscribe(1, 0)
+		defer sub.Close()
+
+		for _, followerID := range []int64{2, 4} {
+			checkResponseCode(t, http.StatusNoContent, request(followerID, http.MethodPut, "/v1/users/1/follow", "").StatusCode)
+		}
+
+		select {
+		case e := <-sub.Events():
+			if e.ActorID != 4 {
+				t.Errorf("expected only user 4's follow, got one from user %d", e.ActorID)
+			}
+		default:
+			t.Fatal("expected a follow event")
+		}
+
+		select {
+		case e := <-sub.Events():
+			t.Errorf("unexpected event from user %d", e.ActorID)
+		default:
+		}
+	})
+
+	t.Run("should unmute a user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(1, http.MethodPut, "/v1/users/2/unmute", "").StatusCode)
+
+		if muted := listMuted(); len(muted) != 1 || muted[0].UserID != 3 {
+			t.Errorf("expected only user 3 to be muted, got %+v", muted)
+		}
+	})
+
+	t.Run("should not unmute a user who isn't muted", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(1, http.MethodPut, "/v1/users/2/unmute", "").StatusCode)
+	})
+
+	t.Run("should reject an invalid user ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodPut, "/v1/users/abc/mute", "").StatusCode)
+		checkResponseCode(t, http.StatusBadRequest, request(1, http.MethodGet, "/v1/users/me/mutes?limit=51", "").StatusCode)
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,30}$`)
+
+// reservedUsernames can't be registered or changed to, compared case-insensitively. They cover staff-looking names
+// and words used in frontend and API paths
+var reservedUsernames = map[string]bool{
+	"about": true, "account": true, "admin": true, "administrator": true, "api": true, "auth": true,
+	"billing": true, "blog": true, "confirm": true, "dashboard": true, "deleted": true, "explore": true,
+	"feed": true, "feeds": true, "gosocial": true, "help": true, "home": true, "login": true, "logout": true,
+	"me": true, "moderator": true, "null": true, "official": true, "privacy": true, "root": true,
+	"search": true, "security": true, "settings": true, "signup": true, "staff": true, "support": true,
+	"system": true, "terms": true, "undefined": true, "user": true, "users": true, "www": true,
+}
+
+// validateUsername implements the "username" validation tag: 3 to 30 letters, digits or underscores, not only
+// digits (so it can't be confused with a user ID) and not reserved
+func validateUsername(fl validator.FieldLevel) bool {
+	username := fl.Field().String()
+
+	if !usernamePattern.MatchString(username) {
+		return false
+	}
+
+	if strings.Trim(username, "0123456789") == "" {
+		return false
+	}
+
+	return !reservedUsernames[strings.ToLower(username)]
+}
+
+type ChangeUsernamePayload struct {
+	Username string `json:"username" validate:"required,username"`
+}
+
+// ChangeUsername godoc
+//
+//	@Summary		Changes the username
+//	@Description	Usernames are 3-30 letters, digits or underscores and some names are reserved. It can be changed once per cooldown period and the old username keeps resolving to the account for a while
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body	ChangeUsernamePayload	true	"New username"
+//	@Success		204
+//	@Failure		400	{object}	error	"Invalid or taken username, or changed too recently"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/username [put]
+func (app *application) changeUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeUsernamePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if payload.Username == user.Username {
+		app.badRequestResponse(w, r, errors.New("new username is the same as the current one"))
+		return
+	}
+
+	ctx := r.Context()
+	cfg := app.config.usernames
+
+	if err := app.store.Users.ChangeUsername(ctx, user.ID, payload.Username, cfg.changeCooldown, cfg.redirectFor); err != nil {
+		switch err {
+		case store.ErrDuplicateUsername, store.ErrUsernameCooldown:
+			app.badRequestResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/u-iDaniel/go-social-app/internal/store"
+)
+
+func TestUsernamePolicy(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"alice", true},
+		{"Bob_42", true},
+		{"ab", false},
+		{"this_username_is_far_too_long_1", false},
+		{"with-dash", false},
+		{"with space", false},
+		{"ünïcode", false},
+		{"12345", false},
+		{"admin", false},
+		{"Support", false},
+		{"api", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			err := Validate.Var(tt.username, "username")
+			if (err == nil) != tt.valid {
+				t.Errorf("username %q: expected valid=%v, got error %v", tt.username, tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestChangeUsername(t *testing.T) {
+	cfg := config{
+		usernames: usernamesConfig{
+			changeCooldown: time.Hour,
+			redirectFor:    time.Hour,
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	tokenFor := func(userID int64) string {
+		token, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"sub": userID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	changeUsername := func(userID int64, username string) int {
+		body := `{"username":"` + username + `"}`
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/username", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokenFor(userID))
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reject a reserved username", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, changeUsername(1, "admin"))
+	})
+
+	t.Run("should enforce the cooldown between changes", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, changeUsername(1, "ada"))
+		checkResponseCode(t, http.StatusBadRequest, changeUsername(1, "ada_l"))
+	})
+
+	t.Run("should hold the old username for its owner", func(t *testing.T) {
+		app.config.usernames.changeCooldown = 0
+
+		checkResponseCode(t, http.StatusNoContent, changeUsername(1, "ada_l"))
+		checkResponseCode(t, http.StatusBadRequest, changeUsername(2, "ada"))
+		checkResponseCode(t, http.StatusBadRequest, changeUsername(2, "ada_l"))
+	})
+
+	t.Run("should resolve the old username to the account", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/by-username/ada", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokenFor(2))
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var res struct {
+			Data store.User `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Data.ID != 1 || res.Data.Username != "ada_l" {
+			t.Errorf("expected user 1 as ada_l, got %d as %q", res.Data.ID, res.Data.Username)
+		}
+	})
+}
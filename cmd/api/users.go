@@ -1,26 +1,36 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/u-iDaniel/go-social-app/internal/events"
 	"github.com/u-iDaniel/go-social-app/internal/store"
 )
 
 type userKey string
 
+type UserProfile struct {
+	*store.User
+	*store.UserStats
+}
+
 const userCtx userKey = "user"
 
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by userID
+//	@Description	Fetches a user profile by userID, with follower, following and post counts
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int64	true	"User ID"
-//	@Success		200		{object}	store.User
+//	@Success		200		{object}	UserProfile
+//	@Success		304
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
@@ -46,8 +56,106 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stats, err := app.store.Users.GetStats(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	profile := &UserProfile{User: user, UserStats: stats}
+
+	// Users have no updated_at so the ETag is derived from the representation itself
+	body, err := json.Marshal(profile)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if checkNotModified(w, r, weakETag(string(body)), time.Time{}) {
+		return
+	}
+
 	// Return response
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil { // note that password will not be returned in the JSON obj since "-" in marshalling field specification
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil { // note that password will not be returned in the JSON obj since "-" in marshalling field specification
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUserByUsername godoc
+//
+//	@Summary		Fetches a user profile by username
+//	@Description	Fetches an active user's profile by username. Recently changed usernames still resolve to the account
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	store.User
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	user, err := app.store.Users.GetByUsername(r.Context(), username)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// SearchUsers godoc
+//
+//	@Summary		Searches the user directory
+//	@Description	Username typeahead using prefix and trigram similarity. Only active users are returned and users the viewer follows rank first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Username or part of it"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	uq := store.UserSearchQuery{
+		Limit:  10,
+		Offset: 0,
+	}
+
+	uq, err := uq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(uq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer := getUserFromContext(r)
+
+	users, err := app.store.Users.Search(r.Context(), viewer.ID, uq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -60,13 +168,15 @@ type FollowUser struct {
 // FollowUser godoc
 //
 //	@Summary		Follow a user
-//	@Description	Follow a user by userID
+//	@Description	Follow a user by userID. Following a private account creates a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path	int64	true	"User ID"
 //	@Success		204
+//	@Success		202	{object}	FollowRequestStatus	"Follow request sent"
 //	@Failure		400	{object}	error	"User payload missing"
+//	@Failure		403	{object}	error	"Blocked"
 //	@Failure		404	{object}	error	"User not found"
 //	@Failure		409	{object}	error	"User already followed"
 //	@Failure		500	{object}	error	"Internal Server Error"
@@ -83,6 +193,39 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
+	userToFollow, err := app.getUser(ctx, userToFollowID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	rel, err := app.store.Followers.GetRelationship(ctx, followerUser.ID, userToFollow.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if rel.Blocking || rel.BlockedBy {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// Private accounts have to approve followers, so only a request is recorded
+	if userToFollow.IsPrivate {
+		if rel.Following {
+			app.conflictResponse(w, r, store.ErrConflict)
+			return
+		}
+
+		app.requestFollow(w, r, followerUser, userToFollow)
+		return
+	}
+
 	if err := app.store.Followers.Follow(ctx, userToFollowID, followerUser.ID); err != nil {
 		switch err {
 		case store.ErrConflict:
@@ -93,6 +236,9 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	follower := map[string]any{"id": followerUser.ID, "username": followerUser.Username}
+	app.publishEvent(ctx, events.TypeUserFollow, followerUser.ID, []int64{userToFollowID}, follower)
+
 	// Return response (204 No Content)
 	// Note that we're not using app.jsonResponse here because it will return a JSON object, but we want a 204 No Content response
 	// which means no body in the response
@@ -123,7 +269,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 
 	ctx := r.Context()
 
-	if err := app.store.Followers.Unfollow(ctx, followerUser.ID, userToUnfollowID); err != nil { // order of which user follows one another doesn't matter as we will be deleting the relationship
+	if err := app.store.Followers.Unfollow(ctx, userToUnfollowID, followerUser.ID); err != nil { // same argument order as Follow so the row it created is the one removed
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -134,6 +280,124 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetFollowers godoc
+//
+//	@Summary		Lists a user's followers
+//	@Description	Lists the active users following a user, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int64	true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.FollowEntry
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.followListResponse(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Lists who a user follows
+//	@Description	Lists the active users a user follows, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int64	true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.FollowEntry
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.followListResponse(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) followListResponse(w http.ResponseWriter, r *http.Request, list func(context.Context, int64, store.PaginatedQuery) ([]store.FollowEntry, error)) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err = pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	entries, err := list(ctx, userID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetRelationship godoc
+//
+//	@Summary		Relationship with a user
+//	@Description	Whether the viewer follows the user, the user follows the viewer, or both, and any blocks between them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int64	true	"User ID"
+//	@Success		200		{object}	store.Relationship
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/relationship [get]
+func (app *application) getRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := getUserFromContext(r)
+
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rel, err := app.store.Followers.GetRelationship(r.Context(), viewer.ID, targetID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, rel); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
 // ActivateUser godoc
 //
 //	@Summary		Activates/Register a user
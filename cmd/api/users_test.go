@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
+	"github.com/u-iDaniel/go-social-app/internal/store"
 	"github.com/u-iDaniel/go-social-app/internal/store/cache"
 )
 
@@ -100,3 +106,233 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil
 	})
 }
+
+func TestSearchUsers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow unauthenticated access", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/search?q=ali", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should require a query", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/search", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should search users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/search?q=ali&limit=5", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestUpdateProfile(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should update profile fields", `{"display_name":"Ada","bio":"Hello","links":["https://example.com"]}`, http.StatusOK},
+		{"should allow clearing the avatar", `{"avatar_url":""}`, http.StatusOK},
+		{"should reject an invalid avatar URL", `{"avatar_url":"not a url"}`, http.StatusBadRequest},
+		{"should reject invalid links", `{"links":["ftp://example.com"]}`, http.StatusBadRequest},
+		{"should reject too many links", `{"links":["https://a.com","https://b.com","https://c.com","https://d.com","https://e.com","https://f.com"]}`, http.StatusBadRequest},
+		{"should reject unknown fields", `{"email":"new@example.com"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should require the current password", `{"new_password":"secret"}`, http.StatusBadRequest},
+		{"should reject an incorrect current password", `{"current_password":"wrong","new_password":"secret"}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/me/password", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestFollowLists(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	ctx := context.Background()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path string) *http.Response {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Result()
+	}
+
+	listIDs := func(path string) []int64 {
+		res := request(http.MethodGet, path)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []store.FollowEntry `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []int64{}
+		for _, e := range body.Data {
+			ids = append(ids, e.UserID)
+		}
+		return ids
+	}
+
+	relationship := func(targetID int64) store.Relationship {
+		res := request(http.MethodGet, fmt.Sprintf("/v1/users/%d/relationship", targetID))
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data store.Relationship `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	// Users 2, 3 and 4 follow user 1 in that order, and user 1 follows user 2 back
+	for _, id := range []int64{2, 3, 4} {
+		if err := app.store.Followers.Follow(ctx, 1, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkResponseCode(t, http.StatusNoContent, request(http.MethodPut, "/v1/users/2/follow").StatusCode)
+
+	if err := app.store.Users.Delete(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should list followers newest first", func(t *testing.T) {
+		if ids := listIDs("/v1/users/1/followers?limit=2"); !slices.Equal(ids, []int64{4, 3}) {
+			t.Errorf("expected followers 4 and 3, got %v", ids)
+		}
+
+		if ids := listIDs("/v1/users/1/followers?limit=2&offset=2"); !slices.Equal(ids, []int64{2}) {
+			t.Errorf("expected follower 2 on the second page, got %v", ids)
+		}
+	})
+
+	t.Run("should list who a user follows", func(t *testing.T) {
+		if ids := listIDs("/v1/users/1/following"); !slices.Equal(ids, []int64{2}) {
+			t.Errorf("expected user 2, got %v", ids)
+		}
+	})
+
+	t.Run("should reject pagination out of bounds", func(t *testing.T) {
+		for _, query := range []string{"limit=51", "limit=0", "offset=-1", "limit=abc"} {
+			checkResponseCode(t, http.StatusBadRequest, request(http.MethodGet, "/v1/users/1/followers?"+query).StatusCode)
+			checkResponseCode(t, http.StatusBadRequest, request(http.MethodGet, "/v1/users/1/following?"+query).StatusCode)
+		}
+	})
+
+	t.Run("should not list an unknown user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(http.MethodGet, "/v1/users/99/followers").StatusCode)
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodGet, "/v1/users/abc/following").StatusCode)
+	})
+
+	t.Run("should count follows on the profile", func(t *testing.T) {
+		res := request(http.MethodGet, "/v1/users/1")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data store.UserStats `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Data.FollowersCount != 3 || body.Data.FollowingCount != 1 {
+			t.Errorf("expected 3 followers and 1 following, got %+v", body.Data)
+		}
+	})
+
+	t.Run("should report a mutual follow", func(t *testing.T) {
+		if rel := relationship(2); !rel.Following || !rel.FollowedBy || !rel.Mutual {
+			t.Errorf("expected a mutual follow with user 2, got %+v", rel)
+		}
+
+		if rel := relationship(3); rel.Following || !rel.FollowedBy || rel.Mutual {
+			t.Errorf("expected user 3 to follow one way, got %+v", rel)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, request(http.MethodPut, "/v1/users/2/unfollow").StatusCode)
+
+		if rel := relationship(2); rel.Following || !rel.FollowedBy || rel.Mutual {
+			t.Errorf("expected the follow to be one way after unfollowing, got %+v", rel)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_posts_search_vector;
+DROP INDEX IF EXISTS idx_comments_search_vector;
+DROP INDEX IF EXISTS idx_users_search_vector;
+
+DROP TRIGGER IF EXISTS posts_search_vector_trigger ON posts;
+DROP TRIGGER IF EXISTS comments_search_vector_trigger ON comments;
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+
+DROP FUNCTION IF EXISTS posts_search_vector_update();
+DROP FUNCTION IF EXISTS comments_search_vector_update();
+DROP FUNCTION IF EXISTS users_search_vector_update();
+
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE comments DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
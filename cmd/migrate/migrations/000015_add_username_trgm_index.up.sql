@@ -0,0 +1,2 @@
+-- Trigram index so username typeahead can use similarity() and the % operator
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS muted_words;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Words, phrases and tags a user does not want to see in their feed and search results
+CREATE TABLE IF NOT EXISTS muted_words (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('word', 'phrase', 'tag')),
+    value citext NOT NULL,
+    expires_at timestamp(0) with time zone, -- NULL means muted until removed
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (user_id, kind, value),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
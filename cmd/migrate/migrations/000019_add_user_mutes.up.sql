@@ -0,0 +1,14 @@
+-- Muting hides a user's posts and notifications from the muter without the muted user knowing
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id bigint NOT NULL,
+    muted_id bigint NOT NULL,
+    expires_at timestamp(0) with time zone, -- NULL means muted until removed
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (muter_id <> muted_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_mutes_muted_id ON user_mutes (muted_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS suggestion_dismissals;
+DROP TABLE IF EXISTS follow_suggestions;
@@ -0,0 +1,24 @@
+-- Precomputed "who to follow" results, rebuilt periodically by the API's suggestions job
+CREATE TABLE IF NOT EXISTS follow_suggestions (
+    user_id bigint NOT NULL,
+    suggested_id bigint NOT NULL,
+    score real NOT NULL,
+    reason VARCHAR(30) NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, suggested_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (suggested_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_user_score ON follow_suggestions (user_id, score DESC);
+
+CREATE TABLE IF NOT EXISTS suggestion_dismissals (
+    user_id bigint NOT NULL,
+    dismissed_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, dismissed_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (dismissed_id) REFERENCES users (id) ON DELETE CASCADE
+);
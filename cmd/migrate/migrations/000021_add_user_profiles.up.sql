@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio VARCHAR(280) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS location VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS links TEXT[] NOT NULL DEFAULT '{}';
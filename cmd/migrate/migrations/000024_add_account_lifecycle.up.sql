@@ -0,0 +1,8 @@
+-- A deactivated account is hidden (is_active = false) until its owner logs in again. Accounts scheduled for
+-- deletion are also deactivated and get purged once deletion_scheduled_at has passed
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deactivated_at timestamp(0) with time zone,
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users (deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
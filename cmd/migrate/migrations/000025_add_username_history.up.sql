@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username_changed_at timestamp(0) with time zone;
+
+-- Old usernames keep resolving to their account, and stay unavailable to others, until expires_at
+CREATE TABLE IF NOT EXISTS username_history (
+    username VARCHAR(255) PRIMARY KEY,
+    user_id bigint NOT NULL,
+    changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_user_id ON username_history (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_invitations_user_id;
+ALTER TABLE user_invitations DROP COLUMN IF EXISTS created_at;
+ALTER TABLE users DROP COLUMN IF EXISTS activated_at;
@@ -0,0 +1,9 @@
+-- Tells accounts that never finished registration apart from deactivated or deleted ones, which are also inactive
+ALTER TABLE users ADD COLUMN IF NOT EXISTS activated_at timestamp(0) with time zone;
+
+UPDATE users SET activated_at = created_at
+WHERE activated_at IS NULL AND (is_active = true OR deactivated_at IS NOT NULL OR username LIKE 'deleted-%');
+
+ALTER TABLE user_invitations ADD COLUMN IF NOT EXISTS created_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_user_invitations_user_id ON user_invitations (user_id);
@@ -0,0 +1,25 @@
+-- totp_secret is encrypted by the API; it is set on enrollment and only counts once totp_enabled_at is set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret bytea;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at timestamp(0) with time zone;
+-- Last time step a code was accepted for, so a code can't be used twice
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    code bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    used_at timestamp(0) with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+-- Issued after the password checks out for an account with 2FA, exchanged with a code for real tokens
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expiry timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+-- Logins started with an external OpenID provider, waiting for the provider to redirect back
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state bytea PRIMARY KEY,
+    provider varchar(50) NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+-- Accounts at external OpenID providers linked to a user, keyed by the provider's stable subject identifier
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    user_id bigint NOT NULL,
+    email citext NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
@@ -0,0 +1,16 @@
+-- Personal access tokens for scripts and bots. Only the SHA-256 hash of the token is kept
+CREATE TABLE IF NOT EXISTS access_tokens (
+    id bigserial PRIMARY KEY,
+    token bytea NOT NULL UNIQUE,
+    user_id bigint NOT NULL,
+    name varchar(100) NOT NULL,
+    scopes text[] NOT NULL DEFAULT '{}',
+    expires_at timestamp(0) with time zone NOT NULL,
+    last_used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_tokens_expires_at ON access_tokens (expires_at);
@@ -0,0 +1,26 @@
+-- One row per login, keyed by the refresh token family the login started. jti is the latest access token issued to
+-- the session so revoking the session can denylist it
+CREATE TABLE IF NOT EXISTS sessions (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    jti uuid,
+    user_agent varchar(255) NOT NULL DEFAULT '',
+    ip varchar(45) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions (expiry);
+
+-- Logins from before sessions were recorded keep working, without a device
+INSERT INTO sessions (id, user_id, created_at, last_seen_at, expiry)
+SELECT rt.family_id, rt.user_id, MIN(rt.created_at), MAX(rt.created_at), MAX(rt.expiry)
+FROM refresh_tokens rt
+JOIN users u ON u.id = rt.user_id AND u.token_generation = rt.token_generation
+WHERE rt.revoked_at IS NULL AND rt.expiry > NOW()
+GROUP BY rt.family_id, rt.user_id
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Recent failed logins per account and per IP. key is the SHA-256 of "email:<address>" or "ip:<address>" so emails
+-- typed into the login form aren't kept
+CREATE TABLE IF NOT EXISTS login_failures (
+    key bytea PRIMARY KEY,
+    failures integer NOT NULL DEFAULT 0,
+    last_failed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    locked_until timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failed_at ON login_failures (last_failed_at);
@@ -0,0 +1,2 @@
+-- Set when a logged in user started the flow to link an external identity rather than to log in
+ALTER TABLE oidc_states ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users (id) ON DELETE CASCADE;
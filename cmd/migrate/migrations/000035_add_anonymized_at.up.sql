@@ -0,0 +1,5 @@
+-- Anonymized accounts are inactive too, but unlike deactivated ones their posts and comments stay visible
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at timestamp(0) with time zone;
+
+UPDATE users SET anonymized_at = NOW()
+WHERE anonymized_at IS NULL AND is_active = false AND deactivated_at IS NULL AND email LIKE 'deleted-%@deleted.invalid';
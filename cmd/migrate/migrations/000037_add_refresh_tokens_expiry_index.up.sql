@@ -0,0 +1,2 @@
+-- Lets the token sweep find expired refresh tokens without scanning the table
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expiry ON refresh_tokens (expiry);
@@ -0,0 +1,3 @@
+-- Tickets die with the session that asked for them, so logging out also stops EventSource reconnects
+ALTER TABLE stream_tickets
+ADD COLUMN IF NOT EXISTS session_id uuid REFERENCES sessions (id) ON DELETE CASCADE;
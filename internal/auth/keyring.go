@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is an RSA or Ed25519 key identified by its RFC 7638 thumbprint. Keys loaded from a public key only verify
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// NewKey wraps an *rsa.PrivateKey, ed25519.PrivateKey, *rsa.PublicKey or ed25519.PublicKey
+func NewKey(key any) (*Key, error) {
+	k := &Key{}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.private, k.public, k.Method = key, &key.PublicKey, jwt.SigningMethodRS256
+	case *rsa.PublicKey:
+		k.public, k.Method = key, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		k.private, k.public, k.Method = key, key.Public(), jwt.SigningMethodEdDSA
+	case ed25519.PublicKey:
+		k.public, k.Method = key, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	thumbprint, err := k.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	k.ID = thumbprint
+
+	return k, nil
+}
+
+// LoadKey reads a PEM encoded PKCS #8 or PKCS #1 private key or a PKIX public key
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return NewKey(key)
+}
+
+// JWK is the public half of a key as published in a JWKS document
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Use: "sig", Algorithm: k.Method.Alg(), KeyID: k.ID}
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// thumbprint hashes the required JWK members in lexicographic order as RFC 7638 specifies
+func (k *Key) thumbprint() (string, error) {
+	jwk := k.JWK()
+
+	var members any
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// KeySetPublisher is implemented by authenticators whose verification keys can be shared with other services
+type KeySetPublisher interface {
+	JWKS() JWKSet
+}
+
+// KeyringAuthenticator signs with one key and verifies with any key in its keyring, chosen by the kid header.
+// Rotating means adding the new key for verification everywhere first, then switching the signing key, then
+// dropping the old key once the tokens it signed have expired
+type KeyringAuthenticator struct {
+	signing *Key
+	keys    map[string]*Key
+	aud     string
+	iss     string
+}
+
+func NewKeyringAuthenticator(signing *Key, verification []*Key, aud, iss string) (*KeyringAuthenticator, error) {
+	if signing == nil || signing.private == nil {
+		return nil, errors.New("signing key must be a private key")
+	}
+
+	keys := map[string]*Key{signing.ID: signing}
+	for _, key := range verification {
+		keys[key.ID] = key
+	}
+
+	return &KeyringAuthenticator{
+		signing: signing,
+		keys:    keys,
+		aud:     aud,
+		iss:     iss,
+	}, nil
+}
+
+func (a *KeyringAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.signing.Method, claims)
+	token.Header["kid"] = a.signing.ID
+
+	return token.SignedString(a.signing.private)
+}
+
+func (a *KeyringAuthenticator) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		// Stops a token from picking a different algorithm than the one the key is meant for
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.public, nil
+	}, jwt.WithExpirationRequired(), jwt.WithAudience(a.aud), jwt.WithIssuer(a.iss), jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+}
+
+// JWKS returns the public keys of every key in the keyring
+func (a *KeyringAuthenticator) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(a.keys))}
+
+	for id, key := range a.keys {
+		if id != a.signing.ID {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+
+	// The signing key comes first so clients that only look at one key pick the current one
+	set.Keys = append([]JWK{a.signing.JWK()}, set.Keys...)
+
+	return set
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeys(t *testing.T) (rsaKey, edKey *Key) {
+	t.Helper()
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsaKey, err = NewKey(rsaPriv); err != nil {
+		t.Fatal(err)
+	}
+	if edKey, err = NewKey(edPriv); err != nil {
+		t.Fatal(err)
+	}
+
+	return rsaKey, edKey
+}
+
+func testTokenClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"aud": "test",
+		"iss": "test",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestKeyringAuthenticator(t *testing.T) {
+	rsaKey, edKey := newTestKeys(t)
+
+	t.Run("should verify tokens signed by every key in the keyring", func(t *testing.T) {
+		oldSigner, err := NewKeyringAuthenticator(rsaKey, nil, "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldToken, err := oldSigner.GenerateToken(testTokenClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// After rotating to the Ed25519 key, tokens from the RSA key must still validate
+		rotated, err := NewKeyringAuthenticator(edKey, []*Key{rsaKey}, "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		newToken, err := rotated.GenerateToken(testTokenClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, token := range []string{oldToken, newToken} {
+			if _, err := rotated.ValidateToken(token); err != nil {
+				t.Errorf("expected token to validate, got %v", err)
+			}
+		}
+
+		if _, err := oldSigner.ValidateToken(newToken); err == nil {
+			t.Error("expected a token from a key outside the keyring to be rejected")
+		}
+	})
+
+	t.Run("should reject HMAC tokens", func(t *testing.T) {
+		a, err := NewKeyringAuthenticator(rsaKey, nil, "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testTokenClaims())
+		token.Header["kid"] = rsaKey.ID
+		signed, err := token.SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.ValidateToken(signed); err == nil {
+			t.Error("expected an HS256 token to be rejected")
+		}
+	})
+
+	t.Run("should require a private signing key", func(t *testing.T) {
+		public, err := NewKey(rsaKey.public)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewKeyringAuthenticator(public, nil, "test", "test"); err == nil {
+			t.Error("expected a public signing key to be rejected")
+		}
+		if public.ID != rsaKey.ID {
+			t.Errorf("expected the public key to share the key ID, got %q and %q", public.ID, rsaKey.ID)
+		}
+	})
+
+	t.Run("should publish the signing key first", func(t *testing.T) {
+		a, err := NewKeyringAuthenticator(edKey, []*Key{rsaKey}, "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := a.JWKS()
+		if len(set.Keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(set.Keys))
+		}
+		if set.Keys[0].KeyID != edKey.ID || set.Keys[0].KeyType != "OKP" || set.Keys[0].Algorithm != "EdDSA" {
+			t.Errorf("unexpected signing key %+v", set.Keys[0])
+		}
+		if set.Keys[1].KeyType != "RSA" || set.Keys[1].E != "AQAB" {
+			t.Errorf("unexpected verification key %+v", set.Keys[1])
+		}
+	})
+}
+
+// Test vector from RFC 8037 appendix A.3
+func TestKeyThumbprint(t *testing.T) {
+	x := "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"
+	public := ed25519.PublicKey(mustDecode(t, x))
+
+	key, err := NewKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; key.ID != want {
+		t.Errorf("expected thumbprint %q, got %q", want, key.ID)
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := jwt.NewParser().DecodeSegment(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -17,8 +17,13 @@ var testClaims = jwt.MapClaims{
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
+// GenerateToken signs claims, or a fixed set of claims for user 1 when claims is nil
 func (t *TestAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims)
+	if claims == nil {
+		claims = testClaims
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
 
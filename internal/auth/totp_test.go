@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// SHA1 test vectors from RFC 6238 appendix B, truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	previous, err := TOTPCode(secret, TOTPStep(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should accept the previous step for clock drift", func(t *testing.T) {
+		step, ok := ValidateTOTP(secret, previous, now)
+		if !ok || step != TOTPStep(now)-1 {
+			t.Errorf("expected step %d to match, got %d %v", TOTPStep(now)-1, step, ok)
+		}
+	})
+
+	t.Run("should reject codes outside the skew", func(t *testing.T) {
+		if _, ok := ValidateTOTP(secret, previous, now.Add(TOTPPeriod*2)); ok {
+			t.Error("expected an old code to be rejected")
+		}
+	})
+
+	t.Run("should reject malformed codes", func(t *testing.T) {
+		if _, ok := ValidateTOTP(secret, "12345", now); ok {
+			t.Error("expected a short code to be rejected")
+		}
+	})
+}
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := box.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(opened) != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("expected the secret back, got %q", opened)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := box.Open(sealed); err == nil {
+		t.Error("expected tampered ciphertext to fail")
+	}
+}
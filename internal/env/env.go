@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func GetString(key, fallback string) string {
@@ -36,3 +37,15 @@ func GetBool(key string, fallback bool) bool {
 	}
 	return valBool
 }
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	valDuration, err := time.ParseDuration(val)
+	if err != nil {
+		return fallback
+	}
+	return valDuration
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	TypeFeedPost      = "feed.post"           // a followed user published a post
+	TypePostComment   = "post.comment"        // someone commented on one of the user's posts
+	TypeUserFollow    = "user.follow"         // someone followed the user
+	TypeFollowRequest = "user.follow_request" // someone asked to follow the user's private account
+)
+
+type Event struct {
+	ID         uint64          `json:"id"`
+	Type       string          `json:"type"`
+	ActorID    int64           `json:"actor_id"`
+	Recipients []int64         `json:"recipients"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// New builds an event for the given recipients, encoding data as the event payload
+func New(eventType string, actorID int64, recipients []int64, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Type:       eventType,
+		ActorID:    actorID,
+		Recipients: recipients,
+		Data:       payload,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+type Broker interface {
+	// Publish assigns the event an ID and delivers it to every connected recipient
+	Publish(ctx context.Context, e Event) error
+	// Subscribe registers a listener for a user. Events newer than lastEventID that are still in the replay
+	// window are queued on the subscription first so clients can resume after reconnecting
+	Subscribe(userID int64, lastEventID uint64) *Subscription
+	// Close ends every subscription and stops accepting new ones
+	Close() error
+}
@@ -0,0 +1,227 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrBrokerClosed = errors.New("event broker is closed")
+
+const subscriptionBuffer = 64
+
+type Subscription struct {
+	UserID int64
+	events chan Event
+	broker *MemoryBroker
+	once   sync.Once
+	// after is the last event replayed, so live events that were also in the replay are not sent twice
+	after uint64
+}
+
+// Events is closed when the subscription ends, either through Close, broker shutdown or because the subscriber
+// fell too far behind. Clients should reconnect with their last event ID in the latter case
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.events) })
+}
+
+// MemoryBroker fans events out to subscribers in this process and keeps a short per-user history for resuming
+type MemoryBroker struct {
+	mu           sync.Mutex
+	subscribers  map[int64]map[*Subscription]struct{}
+	history      map[int64][]Event
+	replayWindow time.Duration
+	historySize  int
+	lastID       atomic.Uint64
+	closed       bool
+	done         chan struct{}
+}
+
+func NewMemoryBroker(replayWindow time.Duration, historySize int) *MemoryBroker {
+	b := &MemoryBroker{
+		subscribers:  make(map[int64]map[*Subscription]struct{}),
+		history:      make(map[int64][]Event),
+		replayWindow: replayWindow,
+		historySize:  historySize,
+		done:         make(chan struct{}),
+	}
+
+	go b.pruneHistory()
+
+	return b
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, e Event) error {
+	e.ID = b.lastID.Add(1)
+	return b.deliver(e)
+}
+
+func (b *MemoryBroker) deliver(e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	for _, userID := range e.Recipients {
+		history := append(b.history[userID], e)
+		if len(history) > b.historySize {
+			history = history[len(history)-b.historySize:]
+		}
+		b.history[userID] = history
+
+		for sub := range b.subscribers[userID] {
+			if e.ID <= sub.after {
+				continue
+			}
+
+			select {
+			case sub.events <- e:
+			default:
+				// Drop slow subscribers rather than blocking publishers; they can resume from the history
+				delete(b.subscribers[userID], sub)
+				sub.close()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(userID int64, lastEventID uint64) *Subscription {
+	return b.subscribe(userID, lastEventID, nil)
+}
+
+// subscribe replays the local history merged with stored, which holds events kept elsewhere such as in Redis
+func (b *MemoryBroker) subscribe(userID int64, lastEventID uint64, stored []Event) *Subscription {
+	// Leave room for a full replay on top of the usual buffer so resuming never drops part of the history
+	sub := &Subscription{
+		UserID: userID,
+		events: make(chan Event, b.historySize+subscriptionBuffer),
+		broker: b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		sub.close()
+		return sub
+	}
+
+	if lastEventID > 0 {
+		for _, e := range b.replay(userID, lastEventID, stored) {
+			sub.events <- e
+			sub.after = e.ID
+		}
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// replay returns the events after lastEventID that are still inside the replay window, oldest first and without
+// duplicates. Callers must hold b.mu
+func (b *MemoryBroker) replay(userID int64, lastEventID uint64, stored []Event) []Event {
+	cutoff := time.Now().Add(-b.replayWindow)
+	seen := make(map[uint64]struct{})
+
+	var events []Event
+	for _, e := range slices.Concat(stored, b.history[userID]) {
+		if _, ok := seen[e.ID]; ok || e.ID <= lastEventID || !e.CreatedAt.After(cutoff) {
+			continue
+		}
+		seen[e.ID] = struct{}{}
+		events = append(events, e)
+	}
+
+	slices.SortFunc(events, func(a, b Event) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if len(events) > b.historySize {
+		events = events[len(events)-b.historySize:]
+	}
+
+	return events
+}
+
+func (b *MemoryBroker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if subs, ok := b.subscribers[sub.UserID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.subscribers, sub.UserID)
+		}
+	}
+	sub.close()
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+
+	for userID, subs := range b.subscribers {
+		for sub := range subs {
+			sub.close()
+		}
+		delete(b.subscribers, userID)
+	}
+
+	return nil
+}
+
+// pruneHistory drops events that fell out of the replay window so idle users don't hold memory forever
+func (b *MemoryBroker) pruneHistory() {
+	ticker := time.NewTicker(b.replayWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-b.replayWindow)
+
+			b.mu.Lock()
+			for userID, history := range b.history {
+				i := 0
+				for i < len(history) && history[i].CreatedAt.Before(cutoff) {
+					i++
+				}
+
+				if i == len(history) {
+					delete(b.history, userID)
+				} else {
+					b.history[userID] = history[i:]
+				}
+			}
+			b.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func publish(t *testing.T, b Broker, recipients ...int64) {
+	t.Helper()
+
+	e, err := New(TypeUserFollow, 99, recipients, map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.Events():
+		if !ok {
+			t.Fatal("subscription closed unexpectedly")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	return Event{}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	t.Run("delivers only to recipients", func(t *testing.T) {
+		b := NewMemoryBroker(time.Minute, 10)
+		defer b.Close()
+
+		alice := b.Subscribe(1, 0)
+		bob := b.Subscribe(2, 0)
+
+		publish(t, b, 1)
+
+		if e := receive(t, alice); e.ID != 1 {
+			t.Errorf("expected event 1, got %d", e.ID)
+		}
+
+		select {
+		case e := <-bob.Events():
+			t.Errorf("bob should not receive event %d", e.ID)
+		default:
+		}
+	})
+
+	t.Run("replays events after the last event ID", func(t *testing.T) {
+		b := NewMemoryBroker(time.Minute, 10)
+		defer b.Close()
+
+		publish(t, b, 1)
+		publish(t, b, 1)
+		publish(t, b, 1)
+
+		sub := b.Subscribe(1, 1)
+		if e := receive(t, sub); e.ID != 2 {
+			t.Errorf("expected replay to start at 2, got %d", e.ID)
+		}
+		if e := receive(t, sub); e.ID != 3 {
+			t.Errorf("expected event 3, got %d", e.ID)
+		}
+	})
+
+	t.Run("replays the whole history", func(t *testing.T) {
+		b := NewMemoryBroker(time.Minute, subscriptionBuffer*2)
+		defer b.Close()
+
+		for range subscriptionBuffer * 2 {
+			publish(t, b, 1)
+		}
+
+		sub := b.Subscribe(1, 1)
+		for id := uint64(2); id <= subscriptionBuffer*2; id++ {
+			if e := receive(t, sub); e.ID != id {
+				t.Fatalf("expected event %d, got %d", id, e.ID)
+			}
+		}
+
+		publish(t, b, 1)
+		if e := receive(t, sub); e.ID != subscriptionBuffer*2+1 {
+			t.Errorf("expected live event after the replay, got %d", e.ID)
+		}
+	})
+
+	t.Run("merges stored history without duplicates", func(t *testing.T) {
+		b := NewMemoryBroker(time.Minute, 10)
+		defer b.Close()
+
+		publish(t, b, 1)
+		publish(t, b, 1)
+
+		// Events kept in Redis, including one this broker has not delivered yet
+		now := time.Now()
+		stored := []Event{{ID: 2, CreatedAt: now}, {ID: 3, CreatedAt: now}}
+
+		sub := b.subscribe(1, 1, stored)
+		for _, id := range []uint64{2, 3} {
+			if e := receive(t, sub); e.ID != id {
+				t.Fatalf("expected event %d, got %d", id, e.ID)
+			}
+		}
+
+		// The replayed event arriving live is skipped
+		for _, id := range []uint64{3, 4} {
+			if err := b.deliver(Event{ID: id, Recipients: []int64{1}, CreatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if e := receive(t, sub); e.ID != 4 {
+			t.Errorf("expected event 4, got %d", e.ID)
+		}
+	})
+
+	t.Run("close ends subscriptions", func(t *testing.T) {
+		b := NewMemoryBroker(time.Minute, 10)
+		sub := b.Subscribe(1, 0)
+
+		b.Close()
+
+		if _, ok := <-sub.Events(); ok {
+			t.Error("expected subscription to be closed")
+		}
+
+		if err := b.Publish(context.Background(), Event{Recipients: []int64{1}}); err != ErrBrokerClosed {
+			t.Errorf("expected ErrBrokerClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	redisChannel  = "events"
+	redisSequence = "events-seq"
+	redisHistory  = "events-history"
+
+	historyTimeout = time.Second * 2
+)
+
+// publishScript takes the next event ID, appends the event to each recipient's capped history and publishes it in
+// one step, so events reach subscribers in ID order and none is published without its ID or missing from a
+// history. Messages are the ID, a space and the JSON event
+var publishScript = redis.NewScript(`
+	local id = redis.call('INCR', KEYS[1])
+	local msg = tostring(id) .. ' ' .. ARGV[1]
+	for i = 3, #KEYS do
+		redis.call('RPUSH', KEYS[i], msg)
+		redis.call('LTRIM', KEYS[i], -tonumber(ARGV[2]), -1)
+		redis.call('PEXPIRE', KEYS[i], ARGV[3])
+	end
+	redis.call('PUBLISH', KEYS[2], msg)
+	return id
+`)
+
+// RedisBroker shares events between API instances through Redis pub/sub. Every instance receives every event and
+// delivers it to its own subscribers through a MemoryBroker. The replay history lives in Redis so a client can
+// resume on any instance
+type RedisBroker struct {
+	rdb          *redis.Client
+	pubsub       *redis.PubSub
+	local        *MemoryBroker
+	replayWindow time.Duration
+	historySize  int
+	onErr        func(error)
+}
+
+func NewRedisBroker(ctx context.Context, rdb *redis.Client, replayWindow time.Duration, historySize int, onErr func(error)) (*RedisBroker, error) {
+	pubsub := rdb.Subscribe(ctx, redisChannel)
+
+	// Wait for the subscription to be confirmed so events published right after startup are not lost
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	b := &RedisBroker{
+		rdb:          rdb,
+		pubsub:       pubsub,
+		local:        NewMemoryBroker(replayWindow, historySize),
+		replayWindow: replayWindow,
+		historySize:  historySize,
+		onErr:        onErr,
+	}
+
+	go b.listen()
+
+	return b, nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{redisSequence, redisChannel}
+	for _, userID := range e.Recipients {
+		keys = append(keys, historyKey(userID))
+	}
+
+	// IDs come from a shared counter so Last-Event-ID means the same thing on every instance
+	return publishScript.Run(ctx, b.rdb, keys, payload, b.historySize, b.replayWindow.Milliseconds()).Err()
+}
+
+// Subscribe replays the user's history from Redis, merged with whatever this instance has delivered since it was
+// read, so nothing published in between is lost or sent twice
+func (b *RedisBroker) Subscribe(userID int64, lastEventID uint64) *Subscription {
+	var stored []Event
+	if lastEventID > 0 {
+		stored = b.history(userID)
+	}
+
+	return b.local.subscribe(userID, lastEventID, stored)
+}
+
+// history reads the user's stored events. Errors are reported and the replay falls back to the local history
+func (b *RedisBroker) history(userID int64) []Event {
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	msgs, err := b.rdb.LRange(ctx, historyKey(userID), 0, -1).Result()
+	if err != nil {
+		b.onErr(err)
+		return nil
+	}
+
+	events := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		e, err := decodeMessage(msg)
+		if err != nil {
+			b.onErr(err)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func (b *RedisBroker) Close() error {
+	err := b.pubsub.Close()
+	if closeErr := b.local.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (b *RedisBroker) listen() {
+	for msg := range b.pubsub.Channel() {
+		e, err := decodeMessage(msg.Payload)
+		if err != nil {
+			b.onErr(err)
+			continue
+		}
+
+		if err := b.local.deliver(e); err != nil {
+			return
+		}
+	}
+}
+
+func historyKey(userID int64) string {
+	return fmt.Sprintf("%s:%d", redisHistory, userID)
+}
+
+// decodeMessage reads an event published by publishScript
+func decodeMessage(msg string) (Event, error) {
+	var e Event
+
+	rawID, payload, ok := strings.Cut(msg, " ")
+	if !ok {
+		return e, fmt.Errorf("malformed event message %q", msg)
+	}
+
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return e, err
+	}
+
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		return e, err
+	}
+
+	e.ID = id
+	return e, nil
+}
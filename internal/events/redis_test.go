@@ -0,0 +1,20 @@
+package events
+
+import "testing"
+
+func TestDecodeMessage(t *testing.T) {
+	e, err := decodeMessage(`42 {"id":0,"type":"user.follow","actor_id":7,"recipients":[1,2],"data":{"n":1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.ID != 42 || e.Type != TypeUserFollow || e.ActorID != 7 || len(e.Recipients) != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	for _, msg := range []string{`{"id":1}`, `x {"id":1}`, `3 not-json`} {
+		if _, err := decodeMessage(msg); err == nil {
+			t.Errorf("expected an error decoding %q", msg)
+		}
+	}
+}
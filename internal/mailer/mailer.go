@@ -3,9 +3,13 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "GoSocial"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName              = "GoSocial"
+	maxRetries            = 3
+	UserWelcomeTemplate   = "user_invitation.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+	EmailChangeTemplate   = "email_change.tmpl"
+	EmailNoticeTemplate   = "email_change_notice.tmpl"
+	LoginLockoutTemplate  = "login_lockout.tmpl"
 )
 
 // The following line embeds the templates directory into the binary using compiler directives (https://gobyexample.com/embed-directive)
@@ -0,0 +1,8 @@
+package mailer
+
+type MockClient struct{}
+
+func (m *MockClient) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	// Mock implementation
+	return 200, nil
+}
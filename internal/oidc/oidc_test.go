@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockServer is a minimal OpenID provider: it hands out codes from /authorize, checks PKCE at /token and signs ID
+// tokens with an RSA key published at /jwks
+type mockServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+	// claims lets a test tamper with the next ID token
+	claims func(jwt.MapClaims)
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockServer(t *testing.T, clientID string) *mockServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockServer{key: key, clientID: clientID, codes: map[string]authRequest{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != clientID || secret != "secret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+
+		m.mu.Lock()
+		req, ok := m.codes[r.FormValue("code")]
+		delete(m.codes, r.FormValue("code"))
+		m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken(t, req.nonce)})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// authorize stands in for the user logging in at the provider and returns the code it would redirect back with
+func (m *mockServer) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != m.clientID {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := "code-" + q.Get("state")
+	m.codes[code] = authRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+
+	return code
+}
+
+func (m *mockServer) idToken(t *testing.T, nonce string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            "user-123",
+		"aud":            m.clientID,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "ada@example.com",
+		"email_verified": true,
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestLoginFlow(t *testing.T) {
+	server := newMockServer(t, "client")
+	provider := NewProvider(Config{
+		IssuerURL:    server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/callback",
+		Scopes:       []string{"email"},
+	}, server.Client())
+
+	ctx := context.Background()
+
+	login := func(t *testing.T, nonce string) (*Claims, error) {
+		t.Helper()
+
+		verifier, err := RandomString()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authURL, err := provider.AuthCodeURL(ctx, "state", nonce, verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return provider.Exchange(ctx, server.authorize(t, authURL), verifier, nonce)
+	}
+
+	t.Run("should return the verified claims", func(t *testing.T) {
+		claims, err := login(t, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claims.Subject != "user-123" || claims.Email != "ada@example.com" || !claims.EmailVerified {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("should reject a wrong PKCE verifier", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := provider.Exchange(ctx, server.authorize(t, authURL), "other-verifier", "nonce"); err == nil {
+			t.Error("expected the exchange to fail")
+		}
+	})
+
+	tests := []struct {
+		name   string
+		tamper func(jwt.MapClaims)
+	}{
+		{"should reject a replayed nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }},
+		{"should reject another audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{"should reject another issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"should reject an expired token", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.claims = tt.tamper
+			defer func() { server.claims = nil }()
+
+			_, err := login(t, "nonce")
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("expected an invalid ID token error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server := newMockServer(t, "client")
+	// The trailing slash makes it a different issuer even though discovery is fetched from the same place
+	provider := NewProvider(Config{IssuerURL: server.URL + "/", ClientID: "client"}, server.Client())
+
+	_, err := provider.Discover(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected an issuer mismatch, got %v", err)
+	}
+}
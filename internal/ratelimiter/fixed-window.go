@@ -6,45 +6,70 @@ import (
 )
 
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int // map of client IP to request count
-	limit   int
-	window  time.Duration
+	sync.Mutex
+	clients    map[string]*clientWindow // map of client IP to its current window
+	order      []string                 // clients in the order their windows started, oldest first
+	limit      int
+	window     time.Duration
+	maxClients int // 0 means no cap
+}
+
+type clientWindow struct {
+	count int
+	start time.Time
 }
 
 func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
+	return NewBoundedFixedWindowLimiter(limit, window, 0)
+}
+
+// NewBoundedFixedWindowLimiter tracks at most maxClients keys at a time, so keys that callers make up (like email
+// addresses) can't grow it without bound. Once it is full the oldest key is forgotten to make room, which gives that
+// key a fresh window but never locks out keys the limiter hasn't seen
+func NewBoundedFixedWindowLimiter(limit int, window time.Duration, maxClients int) *FixedWindowRateLimiter {
 	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
-		limit:   limit,
-		window:  window,
+		clients:    make(map[string]*clientWindow),
+		limit:      limit,
+		window:     window,
+		maxClients: maxClients,
 	}
 }
 
 func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exists := rl.clients[ip]
-	rl.RUnlock()
+	rl.Lock()
+	defer rl.Unlock()
 
-	if !exists || count < rl.limit {
-		rl.Lock()
+	now := time.Now()
+	rl.expire(now)
 
-		// If it's a new client, start a goroutine to reset their count after the window duration
-		if !exists {
-			go rl.resetCount(ip)
+	client, exists := rl.clients[ip]
+	if !exists {
+		if rl.maxClients > 0 && len(rl.clients) >= rl.maxClients {
+			rl.evictOldest()
 		}
-		rl.clients[ip]++
 
-		rl.Unlock()
+		client = &clientWindow{start: now}
+		rl.clients[ip] = client
+		rl.order = append(rl.order, ip)
+	}
 
-		return true, 0 // allowed and doesn't need to wait any time
+	if client.count >= rl.limit {
+		return false, rl.window // note this returns the same cooldown duration regardless if the client has waited a while
 	}
 
-	return false, rl.window // note this returns the same cooldown duration regardless if the client has waited a while
+	client.count++
+
+	return true, 0 // allowed and doesn't need to wait any time
 }
 
-func (rl *FixedWindowRateLimiter) resetCount(ip string) {
-	time.Sleep(rl.window)
-	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
+// expire drops clients whose window is over. Every window is as long, so they end in the order they started
+func (rl *FixedWindowRateLimiter) expire(now time.Time) {
+	for len(rl.order) > 0 && !now.Before(rl.clients[rl.order[0]].start.Add(rl.window)) {
+		rl.evictOldest()
+	}
+}
+
+func (rl *FixedWindowRateLimiter) evictOldest() {
+	delete(rl.clients, rl.order[0])
+	rl.order = rl.order[1:]
 }
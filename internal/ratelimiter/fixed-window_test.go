@@ -0,0 +1,64 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFixedWindowRateLimiter(t *testing.T) {
+	t.Run("limits each key", func(t *testing.T) {
+		rl := NewFixedWindowLimiter(2, time.Minute)
+
+		for i := range 2 {
+			if allow, _ := rl.Allow("a"); !allow {
+				t.Fatalf("request %d should be allowed", i+1)
+			}
+		}
+
+		if allow, retryAfter := rl.Allow("a"); allow || retryAfter != time.Minute {
+			t.Errorf("expected the third request to wait a minute, got %v %s", allow, retryAfter)
+		}
+
+		if allow, _ := rl.Allow("b"); !allow {
+			t.Error("other keys should not be limited")
+		}
+	})
+
+	t.Run("starts a new window once the last one is over", func(t *testing.T) {
+		rl := NewFixedWindowLimiter(1, time.Millisecond*20)
+
+		rl.Allow("a")
+		if allow, _ := rl.Allow("a"); allow {
+			t.Fatal("second request in the window should be refused")
+		}
+
+		time.Sleep(time.Millisecond * 30)
+		if allow, _ := rl.Allow("a"); !allow {
+			t.Error("expected a new window")
+		}
+	})
+
+	t.Run("makes room for new keys when full", func(t *testing.T) {
+		rl := NewBoundedFixedWindowLimiter(1, time.Minute, 3)
+
+		for i := range 10 {
+			if allow, _ := rl.Allow(fmt.Sprintf("made-up-%d", i)); !allow {
+				t.Fatalf("new key %d should be allowed", i)
+			}
+		}
+
+		if allow, _ := rl.Allow("real"); !allow {
+			t.Error("a full limiter should not lock out new keys")
+		}
+
+		if len(rl.clients) != 3 || len(rl.order) != 3 {
+			t.Errorf("expected 3 tracked keys, got %d", len(rl.clients))
+		}
+
+		// The newest keys are still limited
+		if allow, _ := rl.Allow("made-up-9"); allow {
+			t.Error("expected made-up-9 to still be limited")
+		}
+	})
+}
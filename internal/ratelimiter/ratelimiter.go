@@ -10,4 +10,6 @@ type Config struct {
 	RequestsPerTimeFrame int
 	TimeFrame            time.Duration
 	Enabled              bool
+	// MaxClients caps how many keys are tracked at once; 0 means no cap
+	MaxClients int
 }
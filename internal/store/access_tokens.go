@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AccessToken is a named personal access token. The token itself is only returned when it is created
+type AccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type AccessTokenStore struct {
+	db *sql.DB
+}
+
+// Create stores a token under its hash. ErrConflict means the user already has a token with that name
+func (s *AccessTokenStore) Create(ctx context.Context, at *AccessToken, token string) error {
+	query := `
+		INSERT INTO access_tokens (token, user_id, name, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, token, at.UserID, at.Name, pq.Array(at.Scopes), at.ExpiresAt).Scan(
+		&at.ID,
+		&at.CreatedAt,
+	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// GetByUserID lists the user's unexpired tokens, newest first
+func (s *AccessTokenStore) GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM access_tokens
+		WHERE user_id = $1 AND expires_at > NOW()
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		var at AccessToken
+		err := rows.Scan(&at.ID, &at.UserID, &at.Name, pq.Array(&at.Scopes), &at.ExpiresAt, &at.LastUsedAt, &at.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, at)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetByToken looks up an unexpired token by its hash
+func (s *AccessTokenStore) GetByToken(ctx context.Context, token string) (*AccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM access_tokens
+		WHERE token = $1 AND expires_at > NOW();
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	at := &AccessToken{}
+	err := s.db.QueryRowContext(ctx, query, token).Scan(
+		&at.ID,
+		&at.UserID,
+		&at.Name,
+		pq.Array(&at.Scopes),
+		&at.ExpiresAt,
+		&at.LastUsedAt,
+		&at.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return at, nil
+}
+
+// Touch records that the token was used. It writes at most once a minute per token so busy scripts don't turn every
+// request into an update
+func (s *AccessTokenStore) Touch(ctx context.Context, id int64) error {
+	query := `
+		UPDATE access_tokens SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Delete revokes one of the user's tokens
+func (s *AccessTokenStore) Delete(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM access_tokens WHERE id = $1 AND user_id = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *AccessTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM access_tokens WHERE expires_at <= NOW();`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// What happens to an account's content once its deletion grace period is over
+const (
+	DeletionPolicyAnonymize = "anonymize" // posts and comments stay, attributed to a placeholder user
+	DeletionPolicyRemove    = "remove"    // posts, comments and the user row are deleted
+)
+
+// Deactivate hides the account until its owner logs in again
+func (s *UsersStore) Deactivate(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users SET is_active = false, deactivated_at = NOW()
+		WHERE id = $1 AND is_active = true;
+	`
+
+	return s.execAffectingOne(ctx, query, userID)
+}
+
+// ScheduleDeletion deactivates the account and marks it for purging once grace has passed. Logging in before then
+// cancels the deletion
+func (s *UsersStore) ScheduleDeletion(ctx context.Context, userID int64, grace time.Duration) error {
+	query := `
+		UPDATE users SET is_active = false, deactivated_at = NOW(), deletion_scheduled_at = $2
+		WHERE id = $1 AND is_active = true;
+	`
+
+	return s.execAffectingOne(ctx, query, userID, time.Now().Add(grace))
+}
+
+// Reactivate undoes a deactivation or a pending deletion
+func (s *UsersStore) Reactivate(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users SET is_active = true, deactivated_at = NULL, deletion_scheduled_at = NULL
+		WHERE id = $1 AND deactivated_at IS NOT NULL;
+	`
+
+	return s.execAffectingOne(ctx, query, userID)
+}
+
+// RevokeTokens bumps the token generation, which invalidates every access and refresh token issued to the user so far,
+// ends their sessions and deletes their personal access tokens
+func (s *UsersStore) RevokeTokens(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE users SET token_generation = token_generation + 1
+			WHERE id = $1;
+		`
+
+		res, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return execAll(ctx, tx, []string{
+			`DELETE FROM sessions WHERE user_id = $1;`,
+			`DELETE FROM access_tokens WHERE user_id = $1;`,
+		}, userID)
+	})
+}
+
+func (s *UsersStore) execAffectingOne(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted applies policy to every account whose deletion grace period is over and returns how many were
+// purged. Each account is purged in its own transaction so one failure doesn't hold back the rest
+func (s *UsersStore) PurgeDeleted(ctx context.Context, policy string) (int, error) {
+	if policy != DeletionPolicyAnonymize && policy != DeletionPolicyRemove {
+		return 0, fmt.Errorf("unknown deletion policy %q", policy)
+	}
+
+	ids, err := s.getDueForDeletion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var errs []error
+	for _, id := range ids {
+		err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+			if policy == DeletionPolicyRemove {
+				return s.removeAccount(ctx, tx, id)
+			}
+			return s.anonymizeAccount(ctx, tx, id)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purging user %d: %w", id, err))
+			continue
+		}
+
+		purged++
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+func (s *UsersStore) getDueForDeletion(ctx context.Context) ([]int64, error) {
+	query := `
+		SELECT id FROM users WHERE deletion_scheduled_at <= NOW();
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// removeAccount deletes the user's content and the user. Tables referencing users with ON DELETE CASCADE clean up
+// after themselves; posts, comments and invitations predate that and are deleted explicitly
+func (s *UsersStore) removeAccount(ctx context.Context, tx *sql.Tx, userID int64) error {
+	queries := []string{
+		`DELETE FROM comments WHERE user_id = $1 OR post_id IN (SELECT id FROM posts WHERE user_id = $1);`,
+		`DELETE FROM posts WHERE user_id = $1;`,
+		`DELETE FROM user_invitations WHERE user_id = $1;`,
+		`DELETE FROM users WHERE id = $1;`,
+	}
+
+	return execAll(ctx, tx, queries, userID)
+}
+
+// anonymizeAccount strips everything identifying from the user row and drops their social graph, leaving posts and
+// comments in place under a placeholder name. The account can no longer log in
+func (s *UsersStore) anonymizeAccount(ctx context.Context, tx *sql.Tx, userID int64) error {
+	queries := []string{
+		`UPDATE users SET
+			username = 'deleted-' || id,
+			email = 'deleted-' || id || '@deleted.invalid',
+			password = ''::bytea,
+			display_name = '', bio = '', avatar_url = '', location = '', links = '{}',
+			is_active = false, is_private = false, anonymized_at = NOW(),
+			token_generation = token_generation + 1,
+			totp_secret = NULL, totp_enabled_at = NULL,
+			deactivated_at = NULL, deletion_scheduled_at = NULL
+		WHERE id = $1;`,
+		`DELETE FROM followers WHERE user_id = $1 OR follower_id = $1;`,
+		`DELETE FROM follow_requests WHERE requester_id = $1 OR target_id = $1;`,
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1;`,
+		`DELETE FROM user_mutes WHERE muter_id = $1 OR muted_id = $1;`,
+		`DELETE FROM muted_words WHERE user_id = $1;`,
+		`DELETE FROM follow_suggestions WHERE user_id = $1 OR suggested_id = $1;`,
+		`DELETE FROM suggestion_dismissals WHERE user_id = $1 OR dismissed_id = $1;`,
+		`DELETE FROM password_resets WHERE user_id = $1;`,
+		`DELETE FROM email_changes WHERE user_id = $1;`,
+		`DELETE FROM user_invitations WHERE user_id = $1;`,
+		`DELETE FROM username_history WHERE user_id = $1;`,
+		`DELETE FROM refresh_tokens WHERE user_id = $1;`,
+		`DELETE FROM recovery_codes WHERE user_id = $1;`,
+		`DELETE FROM two_factor_challenges WHERE user_id = $1;`,
+		`DELETE FROM user_identities WHERE user_id = $1;`,
+		`DELETE FROM access_tokens WHERE user_id = $1;`,
+		`DELETE FROM sessions WHERE user_id = $1;`,
+	}
+
+	return execAll(ctx, tx, queries, userID)
+}
+
+func execAll(ctx context.Context, tx *sql.Tx, queries []string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
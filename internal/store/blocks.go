@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// BlockedUser is one entry of a user's block list
+type BlockedUser struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	BlockedAt string `json:"blocked_at"`
+}
+
+// notBlockedFilter is a WHERE clause fragment that drops rows whose user in userColumn has blocked, or been blocked
+// by, the viewer in viewerParam
+func notBlockedFilter(userColumn, viewerParam string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = ` + viewerParam + ` AND ub.blocked_id = ` + userColumn + `) OR
+			(ub.blocker_id = ` + userColumn + ` AND ub.blocked_id = ` + viewerParam + `)
+	)`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block records the block and severs every follow and pending follow request between the two users
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2);
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1);
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1);
+		`
+
+		_, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+		return err
+	})
+}
+
+// Unblock lifts a block. Follows removed by the block are not restored
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `
+		DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetBlocked lists the users blockerID has blocked, newest first
+func (s *BlockStore) GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]BlockedUser, error) {
+	query := `
+		SELECT u.id, u.username, ub.created_at
+		FROM user_blocks ub
+		JOIN users u ON u.id = ub.blocked_id
+		WHERE ub.blocker_id = $1
+		ORDER BY ub.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, blockerID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := []BlockedUser{}
+	for rows.Next() {
+		var b BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Username, &b.BlockedAt); err != nil {
+			return nil, err
+		}
+
+		blocked = append(blocked, b)
+	}
+
+	return blocked, rows.Err()
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/u-iDaniel/go-social-app/internal/store"
@@ -9,7 +10,8 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:         &MockUserStore{},
+		RevokedTokens: &MockRevokedTokenStore{},
 	}
 }
 
@@ -28,3 +30,25 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
+
+func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	// Mock implementation
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockRevokedTokenStore struct {
+	mock.Mock
+}
+
+func (m *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	// Mock implementation
+	args := m.Called(jti, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	// Mock implementation
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/u-iDaniel/go-social-app/internal/store"
@@ -11,11 +12,17 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, ttl time.Duration) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:         &UserStore{rdb: rdb},
+		RevokedTokens: &RevokedTokenStore{rdb: rdb},
 	}
 }
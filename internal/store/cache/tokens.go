@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RevokedTokenStore struct {
+	rdb *redis.Client
+}
+
+// Revoke denylists the token ID for ttl, which should be the time left until the token expires
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil // already expired so nothing to deny
+	}
+
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+	return s.rdb.SetEX(ctx, cacheKey, 1, ttl).Err()
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	n, err := s.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
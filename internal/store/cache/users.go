@@ -16,6 +16,12 @@ type UserStore struct {
 
 const UserExpTime = time.Minute
 
+// cachedUser adds the fields store.User keeps out of its JSON but that are needed to authenticate requests
+type cachedUser struct {
+	*store.User
+	TokenGeneration int `json:"token_generation"`
+}
+
 func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
 	if userID <= 0 {
 		return nil, store.ErrNotFound
@@ -32,10 +38,12 @@ func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error)
 
 	var user store.User
 	if data != "" {
-		err := json.Unmarshal([]byte(data), &user)
+		cached := cachedUser{User: &user}
+		err := json.Unmarshal([]byte(data), &cached)
 		if err != nil {
 			return nil, err
 		}
+		user.TokenGeneration = cached.TokenGeneration
 	}
 
 	return &user, nil
@@ -48,10 +56,15 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	// Remember to set TTL for sync with DB
 	cacheKey := fmt.Sprintf("user-%v", user.ID)
-	json, err := json.Marshal(user)
+	json, err := json.Marshal(cachedUser{User: user, TokenGeneration: user.TokenGeneration})
 	if err != nil {
 		return err
 	}
 
 	return s.rdb.SetEX(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user-%v", userID)
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
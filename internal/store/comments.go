@@ -18,18 +18,20 @@ type Comment struct {
 	User      User   `json:"user"`
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID returns a post's comments, leaving out those by deactivated users and users blocked by or blocking
+// viewerID
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
         SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id FROM comments c
         JOIN users on users.id = c.user_id
-        WHERE c.post_id = $1
+        WHERE c.post_id = $1 AND ` + activeAuthorFilter("users") + ` AND ` + notBlockedFilter("c.user_id", "$2") + `
         ORDER BY c.created_at DESC
     `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
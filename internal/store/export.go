@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// UserExport is everything stored about a user, for data portability requests
+type UserExport struct {
+	Profile   *User             `json:"profile"`
+	Posts     []ExportedPost    `json:"posts"`
+	Comments  []ExportedComment `json:"comments"`
+	Followers []FollowEntry     `json:"followers"`
+	Following []FollowEntry     `json:"following"`
+}
+
+type ExportedPost struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+type ExportedComment struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ExportStore struct {
+	db *sql.DB
+}
+
+func (s *ExportStore) Export(ctx context.Context, userID int64) (*UserExport, error) {
+	profile, err := (&UsersStore{db: s.db}).GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExport{Profile: profile}
+
+	if export.Posts, err = s.getPosts(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if export.Comments, err = s.getComments(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Both lists include inactive accounts since the relationship is still part of the user's data
+	followersQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at;
+	`
+	if export.Followers, err = s.getFollows(ctx, followersQuery, userID); err != nil {
+		return nil, err
+	}
+
+	followingQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at;
+	`
+	if export.Following, err = s.getFollows(ctx, followingQuery, userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (s *ExportStore) getPosts(ctx context.Context, userID int64) ([]ExportedPost, error) {
+	query := `
+		SELECT id, title, content, tags, created_at, updated_at
+		FROM posts WHERE user_id = $1
+		ORDER BY created_at;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []ExportedPost{}
+	for rows.Next() {
+		var p ExportedPost
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, pq.Array(&p.Tags), &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+func (s *ExportStore) getComments(ctx context.Context, userID int64) ([]ExportedComment, error) {
+	query := `
+		SELECT id, post_id, content, created_at
+		FROM comments WHERE user_id = $1
+		ORDER BY created_at;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ExportedComment{}
+	for rows.Next() {
+		var c ExportedComment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s *ExportStore) getFollows(ctx context.Context, query string, userID int64) ([]FollowEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FollowEntry{}
+	for rows.Next() {
+		var e FollowEntry
+		if err := rows.Scan(&e.UserID, &e.Username, &e.FollowedAt); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// FollowRequest is a pending follow of a private account, described from the point of view of the listing user
+type FollowRequest struct {
+	UserID    int64  `json:"user_id"` // the other party: requester for incoming, target for outgoing
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+type FollowRequestStore struct {
+	db *sql.DB
+}
+
+func (s *FollowRequestStore) Create(ctx context.Context, requesterID, targetID int64) error {
+	query := `
+		INSERT INTO follow_requests (requester_id, target_id) VALUES ($1, $2);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, requesterID, targetID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+	}
+	return err
+}
+
+// GetIncoming lists requests waiting for targetID to approve them
+func (s *FollowRequestStore) GetIncoming(ctx context.Context, targetID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	query := `
+		SELECT u.id, u.username, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.target_id = $1 AND u.is_active = true
+		ORDER BY fr.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	return s.list(ctx, query, targetID, pq)
+}
+
+// GetOutgoing lists requests requesterID has sent that are still pending
+func (s *FollowRequestStore) GetOutgoing(ctx context.Context, requesterID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	query := `
+		SELECT u.id, u.username, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.target_id
+		WHERE fr.requester_id = $1 AND u.is_active = true
+		ORDER BY fr.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	return s.list(ctx, query, requesterID, pq)
+}
+
+func (s *FollowRequestStore) list(ctx context.Context, query string, userID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.UserID, &fr.Username, &fr.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// Approve turns a pending request into a follow
+func (s *FollowRequestStore) Approve(ctx context.Context, requesterID, targetID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.delete(ctx, tx, requesterID, targetID); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;
+		`
+
+		_, err := tx.ExecContext(ctx, query, requesterID, targetID)
+		return err
+	})
+}
+
+// Delete removes a pending request, used both to reject incoming and to cancel outgoing requests
+func (s *FollowRequestStore) Delete(ctx context.Context, requesterID, targetID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.delete(ctx, tx, requesterID, targetID)
+	})
+}
+
+func (s *FollowRequestStore) delete(ctx context.Context, tx *sql.Tx, requesterID, targetID int64) error {
+	query := `
+		DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
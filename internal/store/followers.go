@@ -13,10 +13,54 @@ type Follower struct {
 	CreatedAt  string `json:"created_at"`
 }
 
+// FollowEntry is one row of a followers or following list
+type FollowEntry struct {
+	UserID     int64  `json:"user_id"`
+	Username   string `json:"username"`
+	FollowedAt string `json:"followed_at"`
+}
+
+type Relationship struct {
+	Following  bool `json:"following"`   // the viewer follows the target
+	FollowedBy bool `json:"followed_by"` // the target follows the viewer
+	Mutual     bool `json:"mutual"`
+	Blocking   bool `json:"blocking"`   // the viewer has blocked the target
+	BlockedBy  bool `json:"blocked_by"` // the target has blocked the viewer
+}
+
+// In the followers table user_id is the account that follows and follower_id is the account being followed
 type FollowerStore struct {
 	db *sql.DB
 }
 
+// GetFollowerIDs returns the IDs of every user following userID
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+		SELECT user_id FROM followers WHERE follower_id = $1;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
 func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
 	query := `
         INSERT INTO followers (user_id, follower_id) VALUES ($1, $2);
@@ -44,3 +88,76 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64)
 	_, err := s.db.ExecContext(ctx, query, userID, followerID)
 	return err
 }
+
+// GetFollowers lists the active users following userID, newest first
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error) {
+	query := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1 AND u.is_active = true
+		ORDER BY f.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	return s.list(ctx, query, userID, pq)
+}
+
+// GetFollowing lists the active users userID follows, newest first
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error) {
+	query := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1 AND u.is_active = true
+		ORDER BY f.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	return s.list(ctx, query, userID, pq)
+}
+
+func (s *FollowerStore) list(ctx context.Context, query string, userID int64, pq PaginatedQuery) ([]FollowEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FollowEntry{}
+	for rows.Next() {
+		var e FollowEntry
+		if err := rows.Scan(&e.UserID, &e.Username, &e.FollowedAt); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *FollowerStore) GetRelationship(ctx context.Context, viewerID, targetID int64) (*Relationship, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2),
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2 AND blocked_id = $1);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rel := &Relationship{}
+	if err := s.db.QueryRowContext(ctx, query, viewerID, targetID).Scan(&rel.Following, &rel.FollowedBy, &rel.Blocking, &rel.BlockedBy); err != nil {
+		return nil, err
+	}
+
+	rel.Mutual = rel.Following && rel.FollowedBy
+
+	return rel, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OIDCState is what the API remembers about a login while the user is at the external provider
+type OIDCState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       int64 // set when linking an identity to this user instead of logging in
+}
+
+type IdentityStore struct {
+	db *sql.DB
+}
+
+// CreateState stores a login under its hashed state value. Expired states are cleared out at the same time
+func (s *IdentityStore) CreateState(ctx context.Context, state string, st *OIDCState, exp time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM oidc_states WHERE expiry <= NOW();`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oidc_states (state, provider, nonce, code_verifier, user_id, expiry)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6);
+	`
+
+	_, err := s.db.ExecContext(ctx, query, state, st.Provider, st.Nonce, st.CodeVerifier, st.UserID, time.Now().Add(exp))
+	return err
+}
+
+// ConsumeState returns and deletes an unexpired login so each state can only complete one login
+func (s *IdentityStore) ConsumeState(ctx context.Context, state string) (*OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE state = $1 AND expiry > NOW()
+		RETURNING provider, nonce, code_verifier, COALESCE(user_id, 0);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	st := &OIDCState{}
+	err := s.db.QueryRowContext(ctx, query, state).Scan(&st.Provider, &st.Nonce, &st.CodeVerifier, &st.UserID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
+
+// GetUser returns the user linked to an external identity. Like GetByEmail it includes deactivated accounts so
+// logging in can reactivate them
+func (s *IdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.token_generation, u.deactivated_at,
+			u.totp_enabled_at IS NOT NULL
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND (u.is_active = true OR u.deactivated_at IS NOT NULL);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.TokenGeneration,
+		&user.DeactivatedAt,
+		&user.TwoFactorEnabled,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// Link attaches an external identity to an existing user. ErrConflict means the identity is already linked
+func (s *IdentityStore) Link(ctx context.Context, userID int64, provider, subject, email string) error {
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, provider, subject, userID, email)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// CreateFromIdentity creates an already activated user for someone logging in with an external provider for the
+// first time and links the identity to it
+func (s *UsersStore) CreateFromIdentity(ctx context.Context, user *User, provider, subject string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// The provider vouched for the email, so there is no invitation to accept
+		query := `UPDATE users SET is_active = true, activated_at = NOW() WHERE id = $1;`
+		if _, err := tx.ExecContext(ctx, query, user.ID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO user_identities (provider, subject, user_id, email)
+			VALUES ($1, $2, $3, $4);
+		`
+
+		_, err := tx.ExecContext(ctx, query, provider, subject, user.ID, user.Email)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RotateInvitation replaces the invitation of an account that never finished registration with a new hashed token
+// and returns the account. ErrNotFound is returned for unknown emails and accounts that were activated before
+func (s *UsersStore) RotateInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	user := &User{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, username, email, created_at
+			FROM users
+			WHERE email = $1 AND is_active = false AND activated_at IS NULL;
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationExp, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SweepInvitations deletes expired invitations, then deletes accounts that were never activated and haven't been
+// sent an activation link for unactivatedFor so their username and email can be registered again. Expired
+// invitations younger than that are kept since they mark when the last link was sent
+func (s *UsersStore) SweepInvitations(ctx context.Context, unactivatedFor time.Duration) (expired, freed int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cutoff := time.Now().Add(-unactivatedFor)
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM user_invitations WHERE expiry <= NOW() AND created_at < $1;`, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if expired, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			WITH stale AS (
+				SELECT u.id FROM users u
+				WHERE u.is_active = false AND u.activated_at IS NULL AND u.created_at < $1 AND NOT EXISTS (
+					SELECT 1 FROM user_invitations ui WHERE ui.user_id = u.id AND ui.created_at >= $1
+				)
+			), invitations AS (
+				DELETE FROM user_invitations WHERE user_id IN (SELECT id FROM stale)
+			)
+			DELETE FROM users WHERE id IN (SELECT id FROM stale);
+		`
+
+		res, err := tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+
+		freed, err = res.RowsAffected()
+		return err
+	})
+
+	return expired, freed, err
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type LoginFailureStore struct {
+	db *sql.DB
+}
+
+// LockedUntil returns the latest lock among keys that is still in force, or the zero time when none is
+func (s *LoginFailureStore) LockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	query := `
+		SELECT MAX(locked_until)
+		FROM login_failures
+		WHERE key = ANY($1::bytea[]) AND locked_until > NOW();
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	byteKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		byteKeys[i] = []byte(key)
+	}
+
+	var until sql.NullTime
+	if err := s.db.QueryRowContext(ctx, query, pq.ByteaArray(byteKeys)).Scan(&until); err != nil {
+		return time.Time{}, err
+	}
+
+	return until.Time, nil
+}
+
+// RecordFailure counts a failed login against key and returns the number of failures so far. The count starts over
+// when the previous failure is older than window
+func (s *LoginFailureStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	query := `
+		INSERT INTO login_failures (key, failures, last_failed_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_failures.last_failed_at <= NOW() - $2 * INTERVAL '1 second' THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failed_at = NOW()
+		RETURNING failures;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var failures int
+	err := s.db.QueryRowContext(ctx, query, key, int64(window.Seconds())).Scan(&failures)
+	return failures, err
+}
+
+// Lock refuses logins for key until the given time
+func (s *LoginFailureStore) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_failures SET locked_until = $2 WHERE key = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+// Reset forgets the failures against key after a successful login
+func (s *LoginFailureStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1;`, key)
+	return err
+}
+
+// DeleteStale drops counters whose last failure is older than window and that aren't locked
+func (s *LoginFailureStore) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	query := `
+		DELETE FROM login_failures
+		WHERE last_failed_at <= NOW() - $1 * INTERVAL '1 second' AND (locked_until IS NULL OR locked_until <= NOW());
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, int64(window.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -2,18 +2,157 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 func NewMockStore() Storage {
+	graph := &mockGraph{}
+
 	return Storage{
-		Users: &MockUsersStore{},
+		Posts:          &MockPostsStore{},
+		Comments:       &MockCommentStore{graph: graph},
+		Users:          &MockUsersStore{graph: graph},
+		Followers:      &MockFollowerStore{graph: graph},
+		FollowRequests: &MockFollowRequestStore{graph: graph},
+		Blocks:         &MockBlockStore{graph: graph},
+		UserMutes:      &MockUserMuteStore{},
+		MutedWords:     &MockMutedWordStore{},
+		RefreshTokens:  &MockRefreshTokenStore{},
+		RevokedTokens:  &MockRevokedTokenStore{},
+		AccessTokens:   &MockAccessTokenStore{},
+		Sessions:       &MockSessionStore{},
+		LoginFailures:  &MockLoginFailureStore{},
+		TwoFactor:      &MockTwoFactorStore{},
+		StreamTickets:  &MockStreamTicketStore{},
+		Identities:     &MockIdentityStore{},
+		Suggestions:    &MockSuggestionStore{},
+	}
+}
+
+// MockPostsStore keeps posts in memory. The author's privacy is whatever was set on the post's User when it was
+// created
+type MockPostsStore struct {
+	mu     sync.Mutex
+	posts  map[int64]Post
+	lastID int64
+}
+
+func (m *MockPostsStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &post, nil
+}
+
+func (m *MockPostsStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	// Mock implementation
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostsStore) GetPublic(ctx context.Context, filter PublicPostsFilter) ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posts := []Post{}
+	for id := m.lastID; id > 0 && len(posts) < filter.Limit; id-- {
+		post, ok := m.posts[id]
+		if !ok {
+			continue
+		}
+
+		if filter.Username != "" && post.User.Username != filter.Username {
+			continue
+		}
+
+		if filter.Tag != "" && !slices.Contains(post.Tags, filter.Tag) {
+			continue
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+func (m *MockPostsStore) Create(ctx context.Context, post *Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.posts == nil {
+		m.posts = make(map[int64]Post)
+	}
+
+	m.lastID++
+	now := time.Now().UTC().Format(time.RFC3339)
+	post.ID, post.CreatedAt, post.UpdatedAt = m.lastID, now, now
+	m.posts[post.ID] = *post
+	return nil
+}
+
+func (m *MockPostsStore) Delete(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.posts[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.posts, id)
+	return nil
+}
+
+func (m *MockPostsStore) Update(ctx context.Context, post *Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.posts[post.ID]
+	if !ok || current.Version != post.Version {
+		return ErrConflict
 	}
+
+	post.Version++
+	post.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	m.posts[post.ID] = *post
+	return nil
 }
 
+// MockUsersStore keeps changed usernames and their redirects, passwords, reset links, token generations and private
+// accounts in memory so the username policy, password and follow request flows can be exercised. Every ID has an
+// account until it is deleted, and every email except those under the reserved .invalid domain
 type MockUsersStore struct {
-	// users []User // In-memory slice to store users (unused for now)
+	graph       *mockGraph
+	mu          sync.Mutex
+	private     map[int64]bool
+	deleted     map[int64]bool
+	usernames   map[int64]mockUsername
+	history     map[string]mockUsernameHistory
+	passwords   map[int64]password
+	resets      map[string]mockPasswordReset
+	generations map[int64]int
+}
+
+type mockPasswordReset struct {
+	userID int64
+	expiry time.Time
+}
+
+type mockUsername struct {
+	username  string
+	changedAt time.Time
+}
+
+type mockUsernameHistory struct {
+	userID    int64
+	expiresAt time.Time
 }
 
 func (m *MockUsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
@@ -26,26 +165,235 @@ func (m *MockUsersStore) CreateAndInvite(ctx context.Context, user *User, token
 	return nil
 }
 
-func (m *MockUsersStore) GetByID(ctx context.Context, id int64) (*User, error) {
+func (m *MockUsersStore) CreateFromIdentity(ctx context.Context, user *User, provider, subject string) error {
 	// Mock implementation
-	return &User{ID: id}, nil
+	return nil
+}
+
+func (m *MockUsersStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deleted[id] {
+		return nil, ErrNotFound
+	}
+
+	return &User{ID: id, Password: m.passwords[id], TokenGeneration: m.generations[id], IsPrivate: m.private[id]}, nil
 }
 
 func (m *MockUsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	// Mock implementation
+	if strings.HasSuffix(email, ".invalid") {
+		return nil, ErrNotFound
+	}
 	return &User{}, nil
 }
 
+func (m *MockUsersStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, current := range m.usernames {
+		if current.username == username {
+			return &User{ID: id, Username: username}, nil
+		}
+	}
+
+	if h, ok := m.history[username]; ok && h.expiresAt.After(time.Now()) {
+		return &User{ID: h.userID, Username: m.usernames[h.userID].username}, nil
+	}
+
+	return &User{Username: username}, nil
+}
+
+// GetStats counts follows from the shared mockGraph. Posts aren't counted
+func (m *MockUsersStore) GetStats(ctx context.Context, userID int64) (*UserStats, error) {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	return &UserStats{
+		FollowersCount: len(m.graph.others(m.graph.follows, edgesTo(userID))),
+		FollowingCount: len(m.graph.others(m.graph.follows, edgesFrom(userID))),
+	}, nil
+}
+
+func (m *MockUsersStore) UpdateProfile(ctx context.Context, user *User) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockUsersStore) ChangeUsername(ctx context.Context, userID int64, username string, cooldown, redirectFor time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.usernames == nil {
+		m.usernames = make(map[int64]mockUsername)
+		m.history = make(map[string]mockUsernameHistory)
+	}
+
+	current, renamed := m.usernames[userID]
+	if renamed && time.Since(current.changedAt) < cooldown {
+		return ErrUsernameCooldown
+	}
+
+	if h, ok := m.history[username]; ok && h.userID != userID && h.expiresAt.After(time.Now()) {
+		return ErrDuplicateUsername
+	}
+
+	for id, other := range m.usernames {
+		if id != userID && other.username == username {
+			return ErrDuplicateUsername
+		}
+	}
+
+	delete(m.history, username)
+	if renamed {
+		m.history[current.username] = mockUsernameHistory{userID: userID, expiresAt: time.Now().Add(redirectFor)}
+	}
+	m.usernames[userID] = mockUsername{username: username, changedAt: time.Now()}
+
+	return nil
+}
+
+func (m *MockUsersStore) SetPrivate(ctx context.Context, userID int64, isPrivate bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.private == nil {
+		m.private = make(map[int64]bool)
+	}
+	m.private[userID] = isPrivate
+
+	if isPrivate {
+		return nil
+	}
+
+	// Going public approves pending requests
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	for edge := range m.graph.requests {
+		if edge.to == userID {
+			delete(m.graph.requests, edge)
+			m.graph.add(&m.graph.follows, edge)
+		}
+	}
+	return nil
+}
+
+func (m *MockUsersStore) Search(ctx context.Context, viewerID int64, uq UserSearchQuery) ([]UserSearchResult, error) {
+	// Mock implementation
+	return []UserSearchResult{}, nil
+}
+
 func (m *MockUsersStore) Activate(ctx context.Context, token string) error {
 	// Mock implementation
 	return nil
 }
 
-func (m *MockUsersStore) Delete(ctx context.Context, id int64) error {
+func (m *MockUsersStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.resets == nil {
+		m.resets = make(map[string]mockPasswordReset)
+	}
+	m.resets[token] = mockPasswordReset{userID: userID, expiry: time.Now().Add(exp)}
+	return nil
+}
+
+func (m *MockUsersStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	reset, ok := m.resets[hashToken]
+	if !ok || !reset.expiry.After(time.Now()) {
+		return ErrNotFound
+	}
+	delete(m.resets, hashToken)
+
+	user.ID = reset.userID
+	if m.passwords == nil {
+		m.passwords = make(map[int64]password)
+	}
+	m.passwords[user.ID] = user.Password
+
+	if m.generations == nil {
+		m.generations = make(map[int64]int)
+	}
+	m.generations[user.ID]++
+	return nil
+}
+
+func (m *MockUsersStore) ChangePassword(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.passwords == nil {
+		m.passwords = make(map[int64]password)
+	}
+	m.passwords[user.ID] = user.Password
+	return nil
+}
+
+func (m *MockUsersStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockUsersStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	// Mock implementation
+	return 1, nil
+}
+
+func (m *MockUsersStore) Deactivate(ctx context.Context, userID int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockUsersStore) ScheduleDeletion(ctx context.Context, userID int64, grace time.Duration) error {
 	// Mock implementation
 	return nil
 }
 
+func (m *MockUsersStore) Reactivate(ctx context.Context, userID int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockUsersStore) PurgeDeleted(ctx context.Context, policy string) (int, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+func (m *MockUsersStore) RotateInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	// Mock implementation
+	return nil, ErrNotFound
+}
+
+func (m *MockUsersStore) SweepInvitations(ctx context.Context, unactivatedFor time.Duration) (int64, int64, error) {
+	// Mock implementation
+	return 0, 0, nil
+}
+
+func (m *MockUsersStore) RevokeTokens(ctx context.Context, userID int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockUsersStore) Delete(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deleted == nil {
+		m.deleted = make(map[int64]bool)
+	}
+	m.deleted[id] = true
+	return nil
+}
+
 func (m *MockUsersStore) Follow(ctx context.Context, followerID, userID int64) error {
 	// Mock implementation
 	return nil
@@ -55,3 +403,830 @@ func (m *MockUsersStore) Unfollow(ctx context.Context, followerID, userID int64)
 	// Mock implementation
 	return nil
 }
+
+// mockGraph holds the follows, follow requests and blocks between users so the stores that share a table in the
+// database share it in the mocks too
+type mockGraph struct {
+	mu       sync.Mutex
+	seq      int
+	follows  map[mockEdge]int // follower to followed, with the order the follow was made in
+	requests map[mockEdge]int // requester to target
+	blocks   map[mockEdge]int // blocker to blocked
+}
+
+type mockEdge struct {
+	from, to int64
+}
+
+// add records edge in edges, reporting false if it was already there. The caller holds mu
+func (g *mockGraph) add(edges *map[mockEdge]int, edge mockEdge) bool {
+	if _, ok := (*edges)[edge]; ok {
+		return false
+	}
+
+	if *edges == nil {
+		*edges = make(map[mockEdge]int)
+	}
+	g.seq++
+	(*edges)[edge] = g.seq
+	return true
+}
+
+// others returns the other user of every edge match picks, newest first. The caller holds mu
+func (g *mockGraph) others(edges map[mockEdge]int, match func(mockEdge) (int64, bool)) []int64 {
+	picked := []mockEdge{}
+	for edge := range edges {
+		if _, ok := match(edge); ok {
+			picked = append(picked, edge)
+		}
+	}
+	slices.SortFunc(picked, func(a, b mockEdge) int { return edges[b] - edges[a] })
+
+	ids := []int64{}
+	for _, edge := range picked {
+		id, _ := match(edge)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// blocked reports whether either user has blocked the other. The caller holds mu
+func (g *mockGraph) blocked(a, b int64) bool {
+	_, ab := g.blocks[mockEdge{a, b}]
+	_, ba := g.blocks[mockEdge{b, a}]
+	return ab || ba
+}
+
+// edgesTo picks the edges that end at userID, matching the user at the other end
+func edgesTo(userID int64) func(mockEdge) (int64, bool) {
+	return func(edge mockEdge) (int64, bool) { return edge.from, edge.to == userID }
+}
+
+// edgesFrom picks the edges that start at userID, matching the user at the other end
+func edgesFrom(userID int64) func(mockEdge) (int64, bool) {
+	return func(edge mockEdge) (int64, bool) { return edge.to, edge.from == userID }
+}
+
+// MockFollowerStore keeps follows in the shared mockGraph. Follow takes its arguments in the same order as the
+// followers table: userID follows followerID
+type MockFollowerStore struct {
+	graph *mockGraph
+}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	if !m.graph.add(&m.graph.follows, mockEdge{userID, followerID}) {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (m *MockFollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	delete(m.graph.follows, mockEdge{userID, followerID})
+	return nil
+}
+
+func (m *MockFollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	return m.graph.others(m.graph.follows, edgesTo(userID)), nil
+}
+
+func (m *MockFollowerStore) GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error) {
+	return m.list(edgesTo(userID), pq), nil
+}
+
+func (m *MockFollowerStore) GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error) {
+	return m.list(edgesFrom(userID), pq), nil
+}
+
+func (m *MockFollowerStore) list(match func(mockEdge) (int64, bool), pq PaginatedQuery) []FollowEntry {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	entries := []FollowEntry{}
+	for _, id := range m.graph.others(m.graph.follows, match) {
+		entries = append(entries, FollowEntry{UserID: id})
+	}
+	return paginate(entries, pq)
+}
+
+func (m *MockFollowerStore) GetRelationship(ctx context.Context, viewerID, targetID int64) (*Relationship, error) {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	rel := &Relationship{}
+	_, rel.Following = m.graph.follows[mockEdge{viewerID, targetID}]
+	_, rel.FollowedBy = m.graph.follows[mockEdge{targetID, viewerID}]
+	_, rel.Blocking = m.graph.blocks[mockEdge{viewerID, targetID}]
+	_, rel.BlockedBy = m.graph.blocks[mockEdge{targetID, viewerID}]
+	rel.Mutual = rel.Following && rel.FollowedBy
+	return rel, nil
+}
+
+// MockFollowRequestStore keeps pending follow requests in the shared mockGraph
+type MockFollowRequestStore struct {
+	graph *mockGraph
+}
+
+func (m *MockFollowRequestStore) Create(ctx context.Context, requesterID, targetID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	if !m.graph.add(&m.graph.requests, mockEdge{requesterID, targetID}) {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (m *MockFollowRequestStore) GetIncoming(ctx context.Context, targetID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	return m.list(edgesTo(targetID), pq), nil
+}
+
+func (m *MockFollowRequestStore) GetOutgoing(ctx context.Context, requesterID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	return m.list(edgesFrom(requesterID), pq), nil
+}
+
+func (m *MockFollowRequestStore) list(match func(mockEdge) (int64, bool), pq PaginatedQuery) []FollowRequest {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	requests := []FollowRequest{}
+	for _, id := range m.graph.others(m.graph.requests, match) {
+		requests = append(requests, FollowRequest{UserID: id})
+	}
+	return paginate(requests, pq)
+}
+
+func (m *MockFollowRequestStore) Approve(ctx context.Context, requesterID, targetID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	edge := mockEdge{requesterID, targetID}
+	if _, ok := m.graph.requests[edge]; !ok {
+		return ErrNotFound
+	}
+	delete(m.graph.requests, edge)
+	m.graph.add(&m.graph.follows, edge)
+	return nil
+}
+
+func (m *MockFollowRequestStore) Delete(ctx context.Context, requesterID, targetID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	edge := mockEdge{requesterID, targetID}
+	if _, ok := m.graph.requests[edge]; !ok {
+		return ErrNotFound
+	}
+	delete(m.graph.requests, edge)
+	return nil
+}
+
+// MockBlockStore keeps blocks in the shared mockGraph
+type MockBlockStore struct {
+	graph *mockGraph
+}
+
+func (m *MockBlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	edge, reverse := mockEdge{blockerID, blockedID}, mockEdge{blockedID, blockerID}
+	if !m.graph.add(&m.graph.blocks, edge) {
+		return ErrConflict
+	}
+
+	delete(m.graph.follows, edge)
+	delete(m.graph.follows, reverse)
+	delete(m.graph.requests, edge)
+	delete(m.graph.requests, reverse)
+	return nil
+}
+
+func (m *MockBlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	edge := mockEdge{blockerID, blockedID}
+	if _, ok := m.graph.blocks[edge]; !ok {
+		return ErrNotFound
+	}
+	delete(m.graph.blocks, edge)
+	return nil
+}
+
+func (m *MockBlockStore) GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]BlockedUser, error) {
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	blocked := []BlockedUser{}
+	for _, id := range m.graph.others(m.graph.blocks, edgesFrom(blockerID)) {
+		blocked = append(blocked, BlockedUser{UserID: id})
+	}
+	return paginate(blocked, pq), nil
+}
+
+// MockCommentStore keeps comments in memory and, like the database, hides those between blocked users
+type MockCommentStore struct {
+	graph    *mockGraph
+	mu       sync.Mutex
+	comments []Comment
+}
+
+func (m *MockCommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.graph.mu.Lock()
+	defer m.graph.mu.Unlock()
+
+	comments := []Comment{}
+	for i := len(m.comments) - 1; i >= 0; i-- {
+		c := m.comments[i]
+		if c.PostID == postID && !m.graph.blocked(viewerID, c.UserID) {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment.ID = int64(len(m.comments) + 1)
+	comment.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	m.comments = append(m.comments, *comment)
+	return nil
+}
+
+// MockMutedWordStore keeps muted words in memory, keyed by ID
+type MockMutedWordStore struct {
+	mu     sync.Mutex
+	words  map[int64]MutedWord
+	lastID int64
+}
+
+func (m *MockMutedWordStore) GetByUserID(ctx context.Context, userID int64) ([]MutedWord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	words := []MutedWord{}
+	for id := m.lastID; id > 0; id-- {
+		mw, ok := m.words[id]
+		if ok && mw.UserID == userID && (mw.ExpiresAt == nil || mw.ExpiresAt.After(time.Now())) {
+			words = append(words, mw)
+		}
+	}
+	return words, nil
+}
+
+func (m *MockMutedWordStore) Create(ctx context.Context, mw *MutedWord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.words == nil {
+		m.words = make(map[int64]MutedWord)
+	}
+
+	// An expired entry for the same value is replaced instead of reported as a duplicate
+	if existing, ok := m.find(mw); ok {
+		if existing.ExpiresAt == nil || existing.ExpiresAt.After(time.Now()) {
+			return ErrConflict
+		}
+		delete(m.words, existing.ID)
+	}
+
+	m.lastID++
+	mw.ID, mw.CreatedAt = m.lastID, time.Now().UTC().Format(time.RFC3339)
+	m.words[mw.ID] = *mw
+	return nil
+}
+
+func (m *MockMutedWordStore) Update(ctx context.Context, mw *MutedWord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.words[mw.ID]
+	if !ok || current.UserID != mw.UserID {
+		return ErrNotFound
+	}
+
+	if existing, ok := m.find(mw); ok && existing.ID != mw.ID {
+		return ErrConflict
+	}
+
+	mw.CreatedAt = current.CreatedAt
+	m.words[mw.ID] = *mw
+	return nil
+}
+
+func (m *MockMutedWordStore) Delete(ctx context.Context, userID, mutedWordID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mw, ok := m.words[mutedWordID]; !ok || mw.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.words, mutedWordID)
+	return nil
+}
+
+// find returns the user's entry with the same kind and value as mw, ignoring case like the citext column. The caller
+// holds mu
+func (m *MockMutedWordStore) find(mw *MutedWord) (MutedWord, bool) {
+	for _, existing := range m.words {
+		if existing.UserID == mw.UserID && existing.Kind == mw.Kind && strings.EqualFold(existing.Value, mw.Value) {
+			return existing, true
+		}
+	}
+	return MutedWord{}, false
+}
+
+// MockUserMuteStore keeps mutes in memory and honours their expiry
+type MockUserMuteStore struct {
+	mu    sync.Mutex
+	seq   int
+	mutes map[mockEdge]mockMute // muter to muted
+}
+
+type mockMute struct {
+	expiresAt *time.Time
+	seq       int
+}
+
+func (m mockMute) active() bool {
+	return m.expiresAt == nil || m.expiresAt.After(time.Now())
+}
+
+func (m *MockUserMuteStore) Mute(ctx context.Context, muterID, mutedID int64, expiresAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mutes == nil {
+		m.mutes = make(map[mockEdge]mockMute)
+	}
+	m.seq++
+	m.mutes[mockEdge{muterID, mutedID}] = mockMute{expiresAt: expiresAt, seq: m.seq}
+	return nil
+}
+
+func (m *MockUserMuteStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	edge := mockEdge{muterID, mutedID}
+	if _, ok := m.mutes[edge]; !ok {
+		return ErrNotFound
+	}
+	delete(m.mutes, edge)
+	return nil
+}
+
+func (m *MockUserMuteStore) GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]UserMute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	edges := []mockEdge{}
+	for edge, mute := range m.mutes {
+		if edge.from == muterID && mute.active() {
+			edges = append(edges, edge)
+		}
+	}
+	slices.SortFunc(edges, func(a, b mockEdge) int { return m.mutes[b].seq - m.mutes[a].seq })
+
+	mutes := []UserMute{}
+	for _, edge := range edges {
+		mutes = append(mutes, UserMute{UserID: edge.to, ExpiresAt: m.mutes[edge].expiresAt})
+	}
+	return paginate(mutes, pq), nil
+}
+
+func (m *MockUserMuteStore) ExcludeMuters(ctx context.Context, mutedID int64, userIDs []int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.DeleteFunc(slices.Clone(userIDs), func(id int64) bool {
+		mute, ok := m.mutes[mockEdge{id, mutedID}]
+		return ok && mute.active()
+	}), nil
+}
+
+type MockRefreshTokenStore struct{}
+
+func (m *MockRefreshTokenStore) Create(ctx context.Context, rt *RefreshToken, token string, exp time.Duration) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error) {
+	// Mock implementation
+	return &RefreshToken{UserID: 1}, nil
+}
+
+func (m *MockRefreshTokenStore) RevokeFamily(ctx context.Context, userID int64, familyID string) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockRefreshTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+// MockRevokedTokenStore keeps the denylist in memory so revocation can be exercised end to end
+type MockRevokedTokenStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func (m *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, userID int64, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.revoked == nil {
+		m.revoked = make(map[string]time.Time)
+	}
+	m.revoked[jti] = expiry
+	return nil
+}
+
+func (m *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.revoked[jti]
+	return ok, nil
+}
+
+func (m *MockRevokedTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+// MockAccessTokenStore keeps tokens in memory, keyed by their hash
+type MockAccessTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*AccessToken
+}
+
+func (m *MockAccessTokenStore) Create(ctx context.Context, at *AccessToken, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tokens == nil {
+		m.tokens = make(map[string]*AccessToken)
+	}
+	at.ID = int64(len(m.tokens) + 1)
+	m.tokens[token] = at
+	return nil
+}
+
+func (m *MockAccessTokenStore) GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []AccessToken{}
+	for _, at := range m.tokens {
+		if at.UserID == userID {
+			tokens = append(tokens, *at)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *MockAccessTokenStore) GetByToken(ctx context.Context, token string) (*AccessToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	at, ok := m.tokens[token]
+	if !ok || !at.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return at, nil
+}
+
+func (m *MockAccessTokenStore) Touch(ctx context.Context, id int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockAccessTokenStore) Delete(ctx context.Context, userID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, at := range m.tokens {
+		if at.ID == id && at.UserID == userID {
+			delete(m.tokens, token)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MockAccessTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+// MockSessionStore keeps sessions in memory. Touch accepts any session so tokens can be issued without a login
+type MockSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func (m *MockSessionStore) Save(ctx context.Context, sess *Session, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions == nil {
+		m.sessions = make(map[string]Session)
+	}
+	m.sessions[sess.ID] = *sess
+	return nil
+}
+
+func (m *MockSessionStore) Touch(ctx context.Context, id string) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockSessionStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := []Session{}
+	for _, sess := range m.sessions {
+		if sess.UserID == userID {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, userID int64, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[id]
+	if !ok || sess.UserID != userID {
+		return nil, ErrNotFound
+	}
+	delete(m.sessions, id)
+	return &sess, nil
+}
+
+func (m *MockSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+type mockLoginFailure struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// MockLoginFailureStore counts failures in memory so lockouts can be exercised end to end
+type MockLoginFailureStore struct {
+	mu       sync.Mutex
+	failures map[string]*mockLoginFailure
+}
+
+func (m *MockLoginFailureStore) LockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var until time.Time
+	for _, key := range keys {
+		if f, ok := m.failures[key]; ok && f.lockedUntil.After(time.Now()) && f.lockedUntil.After(until) {
+			until = f.lockedUntil
+		}
+	}
+	return until, nil
+}
+
+func (m *MockLoginFailureStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failures == nil {
+		m.failures = make(map[string]*mockLoginFailure)
+	}
+	f, ok := m.failures[key]
+	if !ok {
+		f = &mockLoginFailure{}
+		m.failures[key] = f
+	}
+	f.failures++
+	return f.failures, nil
+}
+
+func (m *MockLoginFailureStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.failures[key]; ok {
+		f.lockedUntil = until
+	}
+	return nil
+}
+
+func (m *MockLoginFailureStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+func (m *MockLoginFailureStore) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+// MockIdentityStore keeps OIDC states in memory
+type MockIdentityStore struct {
+	mu     sync.Mutex
+	states map[string]OIDCState
+}
+
+func (m *MockIdentityStore) CreateState(ctx context.Context, state string, st *OIDCState, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.states == nil {
+		m.states = make(map[string]OIDCState)
+	}
+	m.states[state] = *st
+	return nil
+}
+
+func (m *MockIdentityStore) ConsumeState(ctx context.Context, state string) (*OIDCState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.states[state]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.states, state)
+	return &st, nil
+}
+
+func (m *MockIdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	// Mock implementation
+	return nil, ErrNotFound
+}
+
+func (m *MockIdentityStore) Link(ctx context.Context, userID int64, provider, subject, email string) error {
+	// Mock implementation
+	return nil
+}
+
+// MockTwoFactorStore has a pending challenge for every token but no user with 2FA turned on, so every code is wrong
+type MockTwoFactorStore struct{}
+
+func (m *MockTwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	// Mock implementation
+	return nil, ErrNotFound
+}
+
+func (m *MockTwoFactorStore) Enroll(ctx context.Context, userID int64, secret []byte) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	// Mock implementation
+	return ErrNotFound
+}
+
+func (m *MockTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) CreateChallenge(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockTwoFactorStore) AttemptChallenge(ctx context.Context, token string) (*User, error) {
+	// Mock implementation
+	return &User{ID: 1, Email: "grace@example.com"}, nil
+}
+
+func (m *MockTwoFactorStore) DeleteChallenge(ctx context.Context, token string) error {
+	// Mock implementation
+	return nil
+}
+
+// MockStreamTicketStore keeps tickets in memory and honours their expiry
+type MockStreamTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]mockStreamTicket
+}
+
+type mockStreamTicket struct {
+	userID int64
+	expiry time.Time
+}
+
+func (m *MockStreamTicketStore) Create(ctx context.Context, userID int64, sessionID, token string, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tickets == nil {
+		m.tickets = make(map[string]mockStreamTicket)
+	}
+	m.tickets[token] = mockStreamTicket{userID: userID, expiry: time.Now().Add(exp)}
+	return nil
+}
+
+func (m *MockStreamTicketStore) Use(ctx context.Context, token string, exp time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticket, ok := m.tickets[token]
+	if !ok || !ticket.expiry.After(time.Now()) {
+		return 0, ErrNotFound
+	}
+
+	ticket.expiry = time.Now().Add(exp)
+	m.tickets[token] = ticket
+	return ticket.userID, nil
+}
+
+func (m *MockStreamTicketStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// Mock implementation
+	return 0, nil
+}
+
+// MockSuggestionStore serves suggestions set with Set instead of computing them
+type MockSuggestionStore struct {
+	mu          sync.Mutex
+	suggestions map[int64][]Suggestion
+}
+
+// Set replaces the suggestions for userID, best first
+func (m *MockSuggestionStore) Set(userID int64, suggestions []Suggestion) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.suggestions == nil {
+		m.suggestions = make(map[int64][]Suggestion)
+	}
+	m.suggestions[userID] = suggestions
+}
+
+func (m *MockSuggestionStore) Refresh(ctx context.Context, perUser int) error {
+	// Mock implementation
+	return nil
+}
+
+func (m *MockSuggestionStore) Get(ctx context.Context, userID int64, pq PaginatedQuery) ([]Suggestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return paginate(m.suggestions[userID], pq), nil
+}
+
+func (m *MockSuggestionStore) Dismiss(ctx context.Context, userID, dismissedID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.suggestions[userID] = slices.DeleteFunc(m.suggestions[userID], func(s Suggestion) bool {
+		return s.UserID == dismissedID
+	})
+	return nil
+}
+
+// paginate applies a PaginatedQuery to items the way LIMIT and OFFSET would
+func paginate[T any](items []T, pq PaginatedQuery) []T {
+	page := []T{}
+	if pq.Offset < len(items) {
+		page = append(page, items[pq.Offset:min(pq.Offset+pq.Limit, len(items))]...)
+	}
+	return page
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MutedWord struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Kind      string     `json:"kind"` // word, phrase or tag
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// mutedPostsFilter is a WHERE clause fragment that drops posts (aliased p) matching an active muted word, phrase or
+// tag of the user in the given placeholder. Words and phrases are matched against the full-text search vector so
+// they follow the same stemming as search.
+func mutedPostsFilter(userParam string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM muted_words mw
+		WHERE mw.user_id = ` + userParam + ` AND (mw.expires_at IS NULL OR mw.expires_at > NOW()) AND (
+			(mw.kind = 'tag' AND EXISTS (SELECT 1 FROM unnest(p.tags) t WHERE lower(t) = lower(mw.value))) OR
+			(mw.kind <> 'tag' AND p.search_vector @@ phraseto_tsquery('english', mw.value))
+		)
+	)`
+}
+
+// mutedCommentsFilter is the equivalent of mutedPostsFilter for comments (aliased c)
+func mutedCommentsFilter(userParam string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM muted_words mw
+		WHERE mw.user_id = ` + userParam + ` AND (mw.expires_at IS NULL OR mw.expires_at > NOW()) AND
+			mw.kind <> 'tag' AND c.search_vector @@ phraseto_tsquery('english', mw.value)
+	)`
+}
+
+type MutedWordStore struct {
+	db *sql.DB
+}
+
+func (s *MutedWordStore) GetByUserID(ctx context.Context, userID int64) ([]MutedWord, error) {
+	query := `
+		SELECT id, user_id, kind, value, expires_at, created_at
+		FROM muted_words
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []MutedWord{}
+	for rows.Next() {
+		var mw MutedWord
+		if err := rows.Scan(&mw.ID, &mw.UserID, &mw.Kind, &mw.Value, &mw.ExpiresAt, &mw.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		words = append(words, mw)
+	}
+
+	return words, rows.Err()
+}
+
+func (s *MutedWordStore) Create(ctx context.Context, mw *MutedWord) error {
+	// An expired entry for the same value is replaced instead of reported as a duplicate
+	query := `
+		INSERT INTO muted_words (user_id, kind, value, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind, value) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, created_at = NOW()
+		WHERE muted_words.expires_at IS NOT NULL AND muted_words.expires_at <= NOW()
+		RETURNING id, created_at;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, mw.UserID, mw.Kind, mw.Value, mw.ExpiresAt).Scan(&mw.ID, &mw.CreatedAt)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			// The conflicting row is still active so nothing was returned
+			return ErrConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MutedWordStore) Update(ctx context.Context, mw *MutedWord) error {
+	query := `
+		UPDATE muted_words
+		SET kind = $1, value = $2, expires_at = $3
+		WHERE id = $4 AND user_id = $5
+		RETURNING created_at;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, mw.Kind, mw.Value, mw.ExpiresAt, mw.ID, mw.UserID).Scan(&mw.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MutedWordStore) Delete(ctx context.Context, userID, mutedWordID int64) error {
+	query := `
+		DELETE FROM muted_words WHERE id = $1 AND user_id = $2;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, mutedWordID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -26,14 +26,25 @@ type PostWithMetadata struct {
 	CommentCount int `json:"comments_count"`
 }
 
+// PublicPostsFilter narrows the public timeline to a single author and/or tag. Empty fields are ignored
+type PublicPostsFilter struct {
+	Username string
+	Tag      string
+	Limit    int
+}
+
 type PostStore struct {
 	db *sql.DB
 }
 
+// GetByID returns a post with its author's username and privacy. Posts by deactivated authors are not found
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	query := `
-		SELECT id, content, title, user_id, tags, created_at, updated_at, version
-		FROM posts WHERE id = $1;
+		SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, p.updated_at, p.version,
+			u.username, u.is_private
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1 AND ` + activeAuthorFilter("u") + `;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -49,6 +60,8 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Version,
+		&post.User.Username,
+		&post.User.IsPrivate,
 	); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -63,15 +76,19 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
 	query := `
-		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username, COUNT(c.id) AS comments_count 
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.version, p.tags, u.username, COUNT(c.id) AS comments_count 
 		FROM posts p
 		LEFT JOIN comments c ON c.post_id = p.id
 		LEFT JOIN users u ON p.user_id = u.id
 		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
 		WHERE 
 			f.user_id = $1 AND
+			` + activeAuthorFilter("u") + ` AND
 			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-			(p.tags @> $5 OR $5 = '{}')
+			(p.tags @> $5 OR $5 = '{}') AND
+			` + mutedPostsFilter("$1") + ` AND
+			` + notBlockedFilter("p.user_id", "$1") + ` AND
+			` + notMutedUserFilter("p.user_id", "$1") + `
 		GROUP BY p.id, u.username
 		ORDER BY p.created_at ` + fq.Sort + `
 		LIMIT $2 OFFSET $3; 
@@ -95,6 +112,7 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 			&p.Title,
 			&p.Content,
 			&p.CreatedAt,
+			&p.UpdatedAt,
 			&p.Version,
 			pq.Array(&p.Tags),
 			&p.User.Username,
@@ -110,6 +128,55 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	return feed, nil
 }
 
+// GetPublic returns the newest posts that anyone may see, used for syndication feeds
+func (s *PostStore) GetPublic(ctx context.Context, filter PublicPostsFilter) ([]Post, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, u.username
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			` + activeAuthorFilter("u") + ` AND
+			u.is_private = false AND
+			($1 = '' OR u.username = $1) AND
+			($2 = '' OR EXISTS (SELECT 1 FROM unnest(p.tags) t WHERE lower(t) = lower($2)))
+		ORDER BY p.created_at DESC
+		LIMIT $3;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Username, filter.Tag, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			pq.Array(&p.Tags),
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
+			&p.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		p.User.ID = p.UserID
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	query := `
         INSERT INTO posts (content, title, user_id, tags)
@@ -163,9 +230,9 @@ func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	query := `
 		UPDATE posts 
-		SET title = $1, content = $2, version = version + 1
+		SET title = $1, content = $2, version = version + 1, updated_at = NOW()
 		WHERE id = $3 AND version = $4
-		RETURNING version;
+		RETURNING version, updated_at;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -178,7 +245,7 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 		post.Content,
 		post.ID,
 		post.Version,
-	).Scan(&post.Version)
+	).Scan(&post.Version, &post.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	UserID          int64
+	FamilyID        string
+	TokenGeneration int
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Create stores the hashed token as the first of a new family
+func (s *RefreshTokenStore) Create(ctx context.Context, rt *RefreshToken, token string, exp time.Duration) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, token_generation, expiry)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, rt.UserID, rt.FamilyID, rt.TokenGeneration, time.Now().Add(exp))
+	return err
+}
+
+// Rotate spends token and stores newToken in its place within the same family. Presenting a token that was already
+// spent means it leaked, so the whole family is revoked and ErrRefreshTokenReused returned. Expired tokens, families
+// issued before the user's token generation was bumped and tokens of deactivated accounts give ErrNotFound without
+// spending the token, so it still works once the account is reactivated
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error) {
+	rt := &RefreshToken{}
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT rt.user_id, rt.family_id, rt.token_generation, rt.expiry,
+				rt.used_at IS NOT NULL OR rt.revoked_at IS NOT NULL, u.token_generation, u.is_active
+			FROM refresh_tokens rt
+			JOIN users u ON u.id = rt.user_id
+			WHERE rt.token = $1
+			FOR UPDATE OF rt;
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var expiry time.Time
+		var spent bool
+		var currentGeneration int
+		var active bool
+
+		err := tx.QueryRowContext(ctx, query, token).Scan(
+			&rt.UserID,
+			&rt.FamilyID,
+			&rt.TokenGeneration,
+			&expiry,
+			&spent,
+			&currentGeneration,
+			&active,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// The revocation has to be committed, so reuse is reported after the transaction instead of as its error
+		if spent {
+			reused = true
+			return s.revokeFamily(ctx, tx, rt.FamilyID)
+		}
+
+		if !active || time.Now().After(expiry) || rt.TokenGeneration != currentGeneration {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE token = $1;`, token); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO refresh_tokens (token, user_id, family_id, token_generation, expiry)
+			VALUES ($1, $2, $3, $4, $5);
+		`
+
+		_, err = tx.ExecContext(ctx, query, newToken, rt.UserID, rt.FamilyID, rt.TokenGeneration, time.Now().Add(exp))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return rt, nil
+}
+
+// RevokeFamily revokes every refresh token rotated from the same login as familyID and ends its session
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, userID int64, familyID string) error {
+	queries := []string{
+		`UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL;`,
+		`DELETE FROM sessions WHERE user_id = $1 AND id = $2;`,
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return execAll(ctx, tx, queries, userID, familyID)
+	})
+}
+
+// DeleteExpired drops refresh tokens past their expiry. Spent tokens are kept until then so reuse can be detected
+func (s *RefreshTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expiry <= NOW();`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL;
+	`
+
+	if _, err := tx.ExecContext(ctx, query, familyID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1;`, familyID)
+	return err
+}
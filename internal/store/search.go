@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type SearchResult struct {
+	Type      string  `json:"type"` // post, comment or user
+	ID        int64   `json:"id"`
+	PostID    int64   `json:"post_id,omitempty"`
+	UserID    int64   `json:"user_id"`
+	Username  string  `json:"username"`
+	Title     string  `json:"title,omitempty"`
+	Snippet   string  `json:"snippet"` // matches are wrapped in <mark></mark>
+	Rank      float64 `json:"rank"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type SearchQuery struct {
+	Query  string `json:"q" validate:"required,max=100"`
+	Type   string `json:"type" validate:"oneof=all posts comments users"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Offset int    `json:"offset" validate:"gte=0"`
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Query = strings.TrimSpace(qs.Get("q"))
+
+	searchType := qs.Get("type")
+	if searchType != "" {
+		sq.Type = searchType
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Offset = o
+	}
+
+	return sq, nil
+}
+
+// ToTSQuery converts user input into a to_tsquery expression. Words are ANDed together, "quoted text" becomes a
+// phrase, a trailing * makes a prefix match, a leading - negates a term and the keyword OR joins terms with |.
+// Every lexeme is reduced to letters and digits so the result can never contain tsquery syntax from the user.
+// An empty string is returned when the input has nothing searchable.
+func ToTSQuery(input string) string {
+	var terms []string
+	nextOp := "&"
+
+	for _, tok := range tokenizeSearch(input) {
+		if !tok.phrase && tok.text == "OR" {
+			if len(terms) > 0 {
+				nextOp = "|"
+			}
+			continue
+		}
+
+		var term string
+		if tok.phrase {
+			words := searchWords(tok.text)
+			if len(words) == 0 {
+				continue
+			}
+			term = strings.Join(words, " <-> ")
+			if len(words) > 1 {
+				term = "(" + term + ")"
+			}
+		} else {
+			text := tok.text
+			negate := strings.HasPrefix(text, "-")
+			text = strings.TrimLeft(text, "-")
+			prefix := strings.HasSuffix(text, "*")
+			text = strings.TrimRight(text, "*")
+
+			words := searchWords(text)
+			if len(words) == 0 {
+				continue
+			}
+
+			// Words joined by punctuation (e.g. e-mail) are treated as a phrase
+			term = strings.Join(words, " <-> ")
+			if prefix {
+				term += ":*"
+			}
+			if len(words) > 1 {
+				term = "(" + term + ")"
+			}
+			if negate {
+				term = "!" + term
+			}
+		}
+
+		if len(terms) > 0 {
+			terms = append(terms, nextOp)
+		}
+		terms = append(terms, term)
+		nextOp = "&"
+	}
+
+	return strings.Join(terms, " ")
+}
+
+type searchToken struct {
+	text   string
+	phrase bool
+}
+
+func tokenizeSearch(input string) []searchToken {
+	var tokens []searchToken
+
+	for i, part := range strings.Split(input, `"`) {
+		// Odd parts are between quotes; an unterminated quote still counts as a phrase
+		if i%2 == 1 {
+			tokens = append(tokens, searchToken{text: part, phrase: true})
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			tokens = append(tokens, searchToken{text: field})
+		}
+	}
+
+	return tokens
+}
+
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// Search runs a ranked full-text query. Posts and comments the viewer may not see (deactivated authors, private
+// accounts they don't follow or blocks in either direction) or has muted are left out
+func (s *SearchStore) Search(ctx context.Context, viewerID int64, sq SearchQuery) ([]SearchResult, error) {
+	query := `
+		WITH q AS (
+			SELECT to_tsquery('english', $1) AS english, to_tsquery('simple', $1) AS simple
+		)
+		SELECT type, id, post_id, user_id, username, title, snippet, rank, created_at
+		FROM (
+			SELECT 'post' AS type, p.id, p.id AS post_id, p.user_id, u.username, p.title,
+				ts_headline('english', p.content, q.english, $5) AS snippet,
+				ts_rank_cd(p.search_vector, q.english) AS rank, p.created_at
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			CROSS JOIN q
+			WHERE $2 IN ('all', 'posts') AND p.search_vector @@ q.english AND
+				` + activeAuthorFilter("u") + ` AND
+				` + visibleAuthorFilter("u", "$6") + ` AND
+				` + mutedPostsFilter("$6") + ` AND
+				` + notBlockedFilter("u.id", "$6") + `
+
+			UNION ALL
+
+			SELECT 'comment', c.id, c.post_id, c.user_id, u.username, '',
+				ts_headline('english', c.content, q.english, $5),
+				ts_rank_cd(c.search_vector, q.english), c.created_at
+			FROM comments c
+			JOIN users u ON u.id = c.user_id
+			JOIN posts cp ON cp.id = c.post_id
+			JOIN users pu ON pu.id = cp.user_id
+			CROSS JOIN q
+			WHERE $2 IN ('all', 'comments') AND c.search_vector @@ q.english AND
+				` + activeAuthorFilter("u") + ` AND
+				` + activeAuthorFilter("pu") + ` AND
+				` + visibleAuthorFilter("pu", "$6") + ` AND
+				` + mutedCommentsFilter("$6") + ` AND
+				` + notBlockedFilter("u.id", "$6") + ` AND
+				` + notBlockedFilter("pu.id", "$6") + `
+
+			UNION ALL
+
+			SELECT 'user', u.id, 0, u.id, u.username, '',
+				ts_headline('simple', u.username, q.simple, $5),
+				ts_rank_cd(u.search_vector, q.simple), u.created_at
+			FROM users u
+			CROSS JOIN q
+			WHERE $2 IN ('all', 'users') AND u.is_active = true AND u.search_vector @@ q.simple AND
+				` + notBlockedFilter("u.id", "$6") + `
+		) results
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $3 OFFSET $4;
+	`
+
+	headlineOpts := "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, ToTSQuery(sq.Query), sq.Type, sq.Limit, sq.Offset, headlineOpts, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		err := rows.Scan(
+			&res.Type,
+			&res.ID,
+			&res.PostID,
+			&res.UserID,
+			&res.Username,
+			&res.Title,
+			&res.Snippet,
+			&res.Rank,
+			&res.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,29 @@
+package store
+
+import "testing"
+
+func TestToTSQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"golang", "golang"},
+		{"go social", "go & social"},
+		{`"time management" tips`, "(time <-> management) & tips"},
+		{"prod*", "prod:*"},
+		{"yoga -hot", "yoga & !hot"},
+		{"yoga OR pilates", "yoga | pilates"},
+		{"OR yoga", "yoga"},
+		{"e-mail", "(e <-> mail)"},
+		{"a:* & !b | (c)", "a:* & b & c"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"!!! ***", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ToTSQuery(tt.input); got != tt.want {
+			t.Errorf("ToTSQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
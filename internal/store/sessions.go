@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is one login on one device. Its ID is the refresh token family the login started, which access tokens
+// carry as their sid claim
+type Session struct {
+	ID         string `json:"id"`
+	UserID     int64  `json:"-"`
+	JTI        string `json:"-"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// Save records a login, or on refresh updates the session with the new access token and where it was used from
+func (s *SessionStore) Save(ctx context.Context, sess *Session, exp time.Duration) error {
+	query := `
+		INSERT INTO sessions (id, user_id, jti, user_agent, ip, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE
+		SET jti = EXCLUDED.jti, user_agent = EXCLUDED.user_agent, ip = EXCLUDED.ip,
+			last_seen_at = NOW(), expiry = EXCLUDED.expiry
+		WHERE sessions.user_id = EXCLUDED.user_id;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, sess.ID, sess.UserID, sess.JTI, sess.UserAgent, sess.IP, time.Now().Add(exp))
+	return err
+}
+
+// Touch checks the session is still live and records that it was seen. last_seen_at is written at most once a
+// minute so most requests only read
+func (s *SessionStore) Touch(ctx context.Context, id string) error {
+	query := `
+		WITH touched AS (
+			UPDATE sessions SET last_seen_at = NOW()
+			WHERE id = $1 AND expiry > NOW() AND last_seen_at < NOW() - INTERVAL '1 minute'
+		)
+		SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND expiry > NOW());
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByUserID lists the user's live sessions, most recently used first
+func (s *SessionStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, COALESCE(jti::text, ''), user_agent, ip, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1 AND expiry > NOW()
+		ORDER BY last_seen_at DESC, created_at DESC;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var sess Session
+		err := rows.Scan(&sess.ID, &sess.UserID, &sess.JTI, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &sess.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of the user's sessions and revokes its refresh tokens. The deleted session is returned so its
+// latest access token can be denylisted
+func (s *SessionStore) Revoke(ctx context.Context, userID int64, id string) (*Session, error) {
+	sess := &Session{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM sessions
+			WHERE id = $1 AND user_id = $2
+			RETURNING id, user_id, COALESCE(jti::text, ''), user_agent, ip, created_at, last_seen_at;
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, id, userID).Scan(
+			&sess.ID,
+			&sess.UserID,
+			&sess.JTI,
+			&sess.UserAgent,
+			&sess.IP,
+			&sess.CreatedAt,
+			&sess.LastSeenAt,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL;
+		`
+
+		_, err = tx.ExecContext(ctx, query, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (s *SessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expiry <= NOW();`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
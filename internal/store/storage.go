@@ -12,6 +12,7 @@ var (
 	ErrConflict          = errors.New("there was a conflict in updating the resource")
 	ErrDuplicateEmail    = errors.New("email has already been used")
 	ErrDuplicateUsername = errors.New("username has already been used")
+	ErrUsernameCooldown  = errors.New("username was changed too recently")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -19,38 +20,168 @@ type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetPublic(context.Context, PublicPostsFilter) ([]Post, error)
 		Create(context.Context, *Post) error
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
 		Create(context.Context, *Comment) error
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
 		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		CreateFromIdentity(ctx context.Context, user *User, provider, subject string) error
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
+		GetByUsername(context.Context, string) (*User, error)
+		GetStats(context.Context, int64) (*UserStats, error)
+		UpdateProfile(context.Context, *User) error
+		ChangeUsername(ctx context.Context, userID int64, username string, cooldown, redirectFor time.Duration) error
+		SetPrivate(ctx context.Context, userID int64, isPrivate bool) error
+		Search(ctx context.Context, viewerID int64, uq UserSearchQuery) ([]UserSearchResult, error)
 		Activate(context.Context, string) error
+		RotateInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error)
+		SweepInvitations(ctx context.Context, unactivatedFor time.Duration) (expired, freed int64, err error)
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token string, user *User) error
+		ChangePassword(context.Context, *User) error
+		CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error
+		ConfirmEmailChange(ctx context.Context, token string) (int64, error)
+		Deactivate(context.Context, int64) error
+		ScheduleDeletion(ctx context.Context, userID int64, grace time.Duration) error
+		Reactivate(context.Context, int64) error
+		RevokeTokens(context.Context, int64) error
+		PurgeDeleted(ctx context.Context, policy string) (int, error)
 		Delete(context.Context, int64) error
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
+		GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
+		GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error)
+		GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowEntry, error)
+		GetRelationship(ctx context.Context, viewerID, targetID int64) (*Relationship, error)
+	}
+	FollowRequests interface {
+		Create(ctx context.Context, requesterID, targetID int64) error
+		GetIncoming(ctx context.Context, targetID int64, pq PaginatedQuery) ([]FollowRequest, error)
+		GetOutgoing(ctx context.Context, requesterID int64, pq PaginatedQuery) ([]FollowRequest, error)
+		Approve(ctx context.Context, requesterID, targetID int64) error
+		Delete(ctx context.Context, requesterID, targetID int64) error
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+		GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]BlockedUser, error)
+	}
+	UserMutes interface {
+		Mute(ctx context.Context, muterID, mutedID int64, expiresAt *time.Time) error
+		Unmute(ctx context.Context, muterID, mutedID int64) error
+		GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]UserMute, error)
+		ExcludeMuters(ctx context.Context, mutedID int64, userIDs []int64) ([]int64, error)
+	}
+	Suggestions interface {
+		Refresh(ctx context.Context, perUser int) error
+		Get(ctx context.Context, userID int64, pq PaginatedQuery) ([]Suggestion, error)
+		Dismiss(ctx context.Context, userID, dismissedID int64) error
+	}
+	RefreshTokens interface {
+		Create(ctx context.Context, rt *RefreshToken, token string, exp time.Duration) error
+		Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error)
+		RevokeFamily(ctx context.Context, userID int64, familyID string) error
+		DeleteExpired(context.Context) (int64, error)
+	}
+	TwoFactor interface {
+		Get(ctx context.Context, userID int64) (*TwoFactor, error)
+		Enroll(ctx context.Context, userID int64, secret []byte) error
+		Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error
+		Disable(ctx context.Context, userID int64) error
+		UseStep(ctx context.Context, userID, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error
+		CreateChallenge(ctx context.Context, userID int64, token string, exp time.Duration) error
+		AttemptChallenge(ctx context.Context, token string) (*User, error)
+		DeleteChallenge(ctx context.Context, token string) error
+	}
+	Identities interface {
+		CreateState(ctx context.Context, state string, st *OIDCState, exp time.Duration) error
+		ConsumeState(ctx context.Context, state string) (*OIDCState, error)
+		GetUser(ctx context.Context, provider, subject string) (*User, error)
+		Link(ctx context.Context, userID int64, provider, subject, email string) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, userID int64, expiry time.Time) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	AccessTokens interface {
+		Create(ctx context.Context, at *AccessToken, token string) error
+		GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error)
+		GetByToken(ctx context.Context, token string) (*AccessToken, error)
+		Touch(ctx context.Context, id int64) error
+		Delete(ctx context.Context, userID, id int64) error
+		DeleteExpired(context.Context) (int64, error)
+	}
+	Sessions interface {
+		Save(ctx context.Context, sess *Session, exp time.Duration) error
+		Touch(ctx context.Context, id string) error
+		GetByUserID(ctx context.Context, userID int64) ([]Session, error)
+		Revoke(ctx context.Context, userID int64, id string) (*Session, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	LoginFailures interface {
+		LockedUntil(ctx context.Context, keys []string) (time.Time, error)
+		RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+		Lock(ctx context.Context, key string, until time.Time) error
+		Reset(ctx context.Context, key string) error
+		DeleteStale(ctx context.Context, window time.Duration) (int64, error)
+	}
+	StreamTickets interface {
+		Create(ctx context.Context, userID int64, sessionID, token string, exp time.Duration) error
+		Use(ctx context.Context, token string, exp time.Duration) (int64, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	Exports interface {
+		Export(ctx context.Context, userID int64) (*UserExport, error)
 	}
 	Roles interface {
 		GetByName(ctx context.Context, name string) (*Role, error)
 	}
+	Search interface {
+		Search(ctx context.Context, viewerID int64, sq SearchQuery) ([]SearchResult, error)
+	}
+	MutedWords interface {
+		GetByUserID(context.Context, int64) ([]MutedWord, error)
+		Create(context.Context, *MutedWord) error
+		Update(context.Context, *MutedWord) error
+		Delete(ctx context.Context, userID, mutedWordID int64) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UsersStore{db: db},
-		Comments:  &CommentStore{db: db},
-		Followers: &FollowerStore{db: db},
-		Roles:     &RolesStore{db: db},
+		Posts:          &PostStore{db: db},
+		Users:          &UsersStore{db: db},
+		Comments:       &CommentStore{db: db},
+		Followers:      &FollowerStore{db: db},
+		Roles:          &RolesStore{db: db},
+		Search:         &SearchStore{db: db},
+		MutedWords:     &MutedWordStore{db: db},
+		FollowRequests: &FollowRequestStore{db: db},
+		Blocks:         &BlockStore{db: db},
+		UserMutes:      &UserMuteStore{db: db},
+		Suggestions:    &SuggestionStore{db: db},
+		Exports:        &ExportStore{db: db},
+		RefreshTokens:  &RefreshTokenStore{db: db},
+		RevokedTokens:  &RevokedTokenStore{db: db},
+		TwoFactor:      &TwoFactorStore{db: db},
+		Identities:     &IdentityStore{db: db},
+		AccessTokens:   &AccessTokenStore{db: db},
+		Sessions:       &SessionStore{db: db},
+		LoginFailures:  &LoginFailureStore{db: db},
+		StreamTickets:  &StreamTicketStore{db: db},
 	}
 }
 
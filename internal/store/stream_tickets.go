@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type StreamTicketStore struct {
+	db *sql.DB
+}
+
+// Create stores the hashed token of a ticket that lets userID open the event stream. sessionID is the login that
+// asked for it, or empty for personal access tokens
+func (s *StreamTicketStore) Create(ctx context.Context, userID int64, sessionID, token string, exp time.Duration) error {
+	query := `
+		INSERT INTO stream_tickets (token, user_id, session_id, expiry)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, userID, sessionID, time.Now().Add(exp))
+	return err
+}
+
+// Use checks a ticket and keeps it valid for exp longer, returning the user it was issued to. ErrNotFound is
+// returned for unknown and expired tickets, and for tickets whose session has ended
+func (s *StreamTicketStore) Use(ctx context.Context, token string, exp time.Duration) (int64, error) {
+	query := `
+		UPDATE stream_tickets SET expiry = $2
+		WHERE token = $1 AND expiry > NOW()
+		RETURNING user_id;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := s.db.QueryRowContext(ctx, query, token, time.Now().Add(exp)).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// DeleteExpired drops tickets that were never used or whose stream ended
+func (s *StreamTicketStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM stream_tickets WHERE expiry <= NOW();`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Each step of a refresh, like building the shared tables or one batch, walks a large part of the social graph
+	// so it gets far more time than a request query. The run as a whole has no deadline
+	suggestionsStepTimeout = time.Minute * 5
+	// suggestionsBatchSize is how many users get their suggestions rebuilt per transaction
+	suggestionsBatchSize = 500
+	// suggestionsTagAuthors caps the authors per tag, most recent first, that shared tags are matched against so a
+	// popular tag doesn't pair every author with every other
+	suggestionsTagAuthors = 50
+	// suggestionsLockKey is the Postgres advisory lock held while refreshing so only one instance does it at a time
+	suggestionsLockKey = 7_301_036
+)
+
+type Suggestion struct {
+	UserID   int64   `json:"user_id"`
+	Username string  `json:"username"`
+	Score    float64 `json:"score"`
+	Reason   string  `json:"reason"` // followed_by_people_you_follow, shared_tags or popular
+}
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// suggestionExclusions is a WHERE clause fragment that drops candidates (suggestedColumn) that userColumn already
+// follows, has asked to follow, has dismissed, has muted, or has a block with in either direction
+func suggestionExclusions(userColumn, suggestedColumn string) string {
+	return `NOT EXISTS (SELECT 1 FROM followers xf WHERE xf.user_id = ` + userColumn + ` AND xf.follower_id = ` + suggestedColumn + `) AND
+		NOT EXISTS (SELECT 1 FROM follow_requests xr WHERE xr.requester_id = ` + userColumn + ` AND xr.target_id = ` + suggestedColumn + `) AND
+		NOT EXISTS (SELECT 1 FROM suggestion_dismissals xd WHERE xd.user_id = ` + userColumn + ` AND xd.dismissed_id = ` + suggestedColumn + `) AND
+		` + notMutedUserFilter(suggestedColumn, userColumn) + ` AND
+		` + notBlockedFilter(suggestedColumn, userColumn)
+}
+
+// Refresh rebuilds every user's suggestions, keeping the best perUser of them. Candidates come from accounts
+// followed by people the user follows (friends of friends), authors sharing tags with the user's posts and the most
+// followed accounts, and are ranked by the weight of those signals plus the candidate's popularity. Users are
+// rebuilt in batches, each with its own timeout, and instances that find another one refreshing skip the run
+func (s *SuggestionStore) Refresh(ctx context.Context, perUser int) error {
+	// The advisory lock and the temporary tables belong to the session, so everything runs on one connection, held
+	// for as long as the job's ctx
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := s.withTimeout(ctx, QueryTimeoutDuration, func(ctx context.Context) error {
+		return conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1);`, suggestionsLockKey).Scan(&locked)
+	}); err != nil {
+		return err
+	}
+
+	if !locked {
+		return nil
+	}
+
+	defer func() {
+		// Cleaned up even when ctx ran out, or the lock and tables would stay with the pooled connection
+		ctx, cancel := context.WithTimeout(context.Background(), QueryTimeoutDuration)
+		defer cancel()
+
+		_, _ = conn.ExecContext(ctx, `DROP TABLE IF EXISTS pg_temp.suggestion_popularity, pg_temp.suggestion_tag_authors;`)
+		_, _ = conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1);`, suggestionsLockKey)
+	}()
+
+	// Popularity and tag authors don't depend on the user, so they are computed once per run
+	setup := []string{
+		`DROP TABLE IF EXISTS pg_temp.suggestion_popularity, pg_temp.suggestion_tag_authors;`,
+		`CREATE TEMP TABLE suggestion_popularity AS
+			SELECT follower_id AS user_id, COUNT(*) AS followers
+			FROM followers
+			GROUP BY follower_id;`,
+		`CREATE TEMP TABLE suggestion_tag_authors AS
+			SELECT user_id, tag FROM (
+				SELECT user_id, tag, row_number() OVER (PARTITION BY tag ORDER BY last_used_at DESC, user_id) AS n
+				FROM (
+					SELECT p.user_id, lower(t.tag) AS tag, MAX(p.created_at) AS last_used_at
+					FROM posts p, unnest(p.tags) AS t(tag)
+					GROUP BY p.user_id, lower(t.tag)
+				) used
+			) ranked
+			WHERE n <= ` + strconv.Itoa(suggestionsTagAuthors) + `;`,
+		`CREATE INDEX ON suggestion_tag_authors (tag);`,
+	}
+
+	for _, query := range setup {
+		if err := s.withTimeout(ctx, suggestionsStepTimeout, func(ctx context.Context) error {
+			_, err := conn.ExecContext(ctx, query)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		SELECT COALESCE(array_agg(id ORDER BY id), '{}')
+		FROM (SELECT id FROM users WHERE is_active = true AND id > $1 ORDER BY id LIMIT $2) batch;
+	`
+
+	var lastID int64
+	for {
+		var ids []int64
+		if err := s.withTimeout(ctx, QueryTimeoutDuration, func(ctx context.Context) error {
+			return conn.QueryRowContext(ctx, query, lastID, suggestionsBatchSize).Scan(pq.Array(&ids))
+		}); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		if err := s.withTimeout(ctx, suggestionsStepTimeout, func(ctx context.Context) error {
+			return s.refreshBatch(ctx, conn, ids, perUser)
+		}); err != nil {
+			return err
+		}
+
+		lastID = ids[len(ids)-1]
+	}
+
+	// Users that were deactivated since the last run aren't in any batch
+	return s.withTimeout(ctx, suggestionsStepTimeout, func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, `DELETE FROM follow_suggestions fs USING users u WHERE u.id = fs.user_id AND u.is_active = false;`)
+		return err
+	})
+}
+
+// withTimeout runs one step of a refresh under its own deadline
+func (s *SuggestionStore) withTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return fn(ctx)
+}
+
+// refreshBatch replaces the suggestions of the users in ids in one transaction, using the temporary tables Refresh
+// set up on conn
+func (s *SuggestionStore) refreshBatch(ctx context.Context, conn *sql.Conn, ids []int64, perUser int) error {
+	query := `
+		WITH candidates AS (
+			SELECT f1.user_id, f2.follower_id AS suggested_id, 3.0 AS weight, 'followed_by_people_you_follow' AS reason
+			FROM followers f1
+			JOIN followers f2 ON f2.user_id = f1.follower_id
+			WHERE f1.user_id = ANY($2)
+
+			UNION ALL
+
+			SELECT mine.user_id, theirs.user_id, 1.0, 'shared_tags'
+			FROM (SELECT DISTINCT user_id, lower(unnest(tags)) AS tag FROM posts WHERE user_id = ANY($2)) mine
+			JOIN suggestion_tag_authors theirs ON theirs.tag = mine.tag AND theirs.user_id <> mine.user_id
+
+			UNION ALL
+
+			SELECT u.id, top.user_id, 0.0, 'popular'
+			FROM unnest($2::bigint[]) AS u(id)
+			CROSS JOIN (SELECT user_id FROM suggestion_popularity ORDER BY followers DESC, user_id LIMIT 20) top
+		),
+		scored AS (
+			SELECT c.user_id, c.suggested_id,
+				SUM(c.weight) + ln(1 + COALESCE(MAX(p.followers), 0)) AS score,
+				(array_agg(c.reason ORDER BY c.weight DESC))[1] AS reason
+			FROM candidates c
+			JOIN users su ON su.id = c.suggested_id AND su.is_active = true
+			LEFT JOIN suggestion_popularity p ON p.user_id = c.suggested_id
+			WHERE c.suggested_id <> c.user_id AND
+				` + suggestionExclusions("c.user_id", "c.suggested_id") + `
+			GROUP BY c.user_id, c.suggested_id
+		),
+		ranked AS (
+			SELECT *, row_number() OVER (PARTITION BY user_id ORDER BY score DESC, suggested_id) AS rank
+			FROM scored
+		)
+		INSERT INTO follow_suggestions (user_id, suggested_id, score, reason)
+		SELECT user_id, suggested_id, score, reason FROM ranked WHERE rank <= $1;
+	`
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM follow_suggestions WHERE user_id = ANY($1);`, pq.Array(ids)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, perUser, pq.Array(ids)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the precomputed suggestions for userID, re-checking exclusions that may have changed since the last
+// refresh
+func (s *SuggestionStore) Get(ctx context.Context, userID int64, pq PaginatedQuery) ([]Suggestion, error) {
+	query := `
+		SELECT u.id, u.username, fs.score, fs.reason
+		FROM follow_suggestions fs
+		JOIN users u ON u.id = fs.suggested_id
+		WHERE fs.user_id = $1 AND u.is_active = true AND
+			` + suggestionExclusions("fs.user_id", "fs.suggested_id") + `
+		ORDER BY fs.score DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var sg Suggestion
+		if err := rows.Scan(&sg.UserID, &sg.Username, &sg.Score, &sg.Reason); err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, sg)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// Dismiss stops dismissedID from being suggested to userID again
+func (s *SuggestionStore) Dismiss(ctx context.Context, userID, dismissedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO suggestion_dismissals (user_id, dismissed_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;
+		`
+
+		if _, err := tx.ExecContext(ctx, query, userID, dismissedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_suggestions WHERE user_id = $1 AND suggested_id = $2;
+		`
+
+		_, err := tx.ExecContext(ctx, query, userID, dismissedID)
+		return err
+	})
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrCodeReused = errors.New("two-factor code has already been used")
+
+// MaxChallengeAttempts is how many codes can be tried against one login challenge before it stops working
+const MaxChallengeAttempts = 5
+
+// TwoFactor is a user's TOTP enrollment. Secret is encrypted by the caller and is pending until EnabledAt is set
+type TwoFactor struct {
+	UserID    int64
+	Secret    []byte
+	EnabledAt *time.Time
+	LastStep  int64
+}
+
+type TwoFactorStore struct {
+	db *sql.DB
+}
+
+// Get returns the user's enrollment, pending or enabled. ErrNotFound means the user never enrolled
+func (s *TwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	query := `
+		SELECT id, totp_secret, totp_enabled_at, totp_last_step
+		FROM users
+		WHERE id = $1 AND totp_secret IS NOT NULL;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tf := &TwoFactor{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&tf.UserID, &tf.Secret, &tf.EnabledAt, &tf.LastStep)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tf, nil
+}
+
+// Enroll stores a pending secret, replacing any earlier pending one. ErrConflict is returned when 2FA is already on
+func (s *TwoFactorStore) Enroll(ctx context.Context, userID int64, secret []byte) error {
+	query := `
+		UPDATE users SET totp_secret = $2
+		WHERE id = $1 AND totp_enabled_at IS NULL;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// Enable turns on the pending enrollment, remembering the step its first code was for, and replaces the recovery
+// codes with the given hashes
+func (s *TwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2
+			WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+// Disable removes the secret, recovery codes and open login challenges
+func (s *TwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		queries := []string{
+			`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1;`,
+			`DELETE FROM recovery_codes WHERE user_id = $1;`,
+			`DELETE FROM two_factor_challenges WHERE user_id = $1;`,
+		}
+
+		return execAll(ctx, tx, queries, userID)
+	})
+}
+
+// UseStep records that a code for step was accepted. ErrCodeReused is returned for the last accepted step or an
+// earlier one, which stops a code that was seen over someone's shoulder from being replayed
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+	query := `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND totp_last_step < $2;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrCodeReused
+	}
+
+	return nil
+}
+
+// UseRecoveryCode spends one of the user's recovery codes by its hash. ErrNotFound covers unknown and spent codes
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of an enabled enrollment for the given hashes
+func (s *TwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+func (s *TwoFactorStore) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (code, user_id) VALUES ($1, $2);`, code, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateChallenge stores the hashed token of a login that still needs a second factor
+func (s *TwoFactorStore) CreateChallenge(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	query := `
+		INSERT INTO two_factor_challenges (token, user_id, expiry)
+		VALUES ($1, $2, $3);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+	return err
+}
+
+// AttemptChallenge counts an attempt against an unexpired challenge and returns the user logging in, including
+// deactivated accounts so they can be reactivated once the login completes. ErrNotFound is returned once the
+// challenge is used up
+func (s *TwoFactorStore) AttemptChallenge(ctx context.Context, token string) (*User, error) {
+	query := `
+		WITH challenge AS (
+			UPDATE two_factor_challenges SET attempts = attempts + 1
+			WHERE token = $1 AND expiry > NOW() AND attempts < $2
+			RETURNING user_id
+		)
+		SELECT u.id, u.username, u.email, u.created_at, u.token_generation, u.deactivated_at
+		FROM users u
+		JOIN challenge c ON c.user_id = u.id
+		WHERE u.is_active = true OR u.deactivated_at IS NOT NULL;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, token, MaxChallengeAttempts).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.TokenGeneration,
+		&user.DeactivatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// DeleteChallenge removes a challenge once the login completed, along with any expired challenges
+func (s *TwoFactorStore) DeleteChallenge(ctx context.Context, token string) error {
+	query := `DELETE FROM two_factor_challenges WHERE token = $1 OR expiry <= NOW();`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token)
+	return err
+}
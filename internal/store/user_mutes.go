@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type UserMute struct {
+	UserID    int64      `json:"user_id"` // the muted user
+	Username  string     `json:"username"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// notMutedUserFilter is a WHERE clause fragment that drops rows whose user in userColumn is actively muted by the
+// viewer in viewerParam
+func notMutedUserFilter(userColumn, viewerParam string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_mutes um
+		WHERE um.muter_id = ` + viewerParam + ` AND um.muted_id = ` + userColumn + ` AND
+			(um.expires_at IS NULL OR um.expires_at > NOW())
+	)`
+}
+
+type UserMuteStore struct {
+	db *sql.DB
+}
+
+// Mute mutes mutedID for muterID until expiresAt, or indefinitely when it is nil. Muting again replaces the expiry
+func (s *UserMuteStore) Mute(ctx context.Context, muterID, mutedID int64, expiresAt *time.Time) error {
+	query := `
+		INSERT INTO user_mutes (muter_id, muted_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (muter_id, muted_id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, created_at = NOW();
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID, expiresAt)
+	return err
+}
+
+func (s *UserMuteStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `
+		DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetMuted lists the users muterID currently mutes, newest first
+func (s *UserMuteStore) GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]UserMute, error) {
+	query := `
+		SELECT u.id, u.username, um.expires_at, um.created_at
+		FROM user_mutes um
+		JOIN users u ON u.id = um.muted_id
+		WHERE um.muter_id = $1 AND (um.expires_at IS NULL OR um.expires_at > NOW())
+		ORDER BY um.created_at DESC, u.id
+		LIMIT $2 OFFSET $3;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, muterID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := []UserMute{}
+	for rows.Next() {
+		var m UserMute
+		if err := rows.Scan(&m.UserID, &m.Username, &m.ExpiresAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		mutes = append(mutes, m)
+	}
+
+	return mutes, rows.Err()
+}
+
+// ExcludeMuters returns userIDs without the users that currently mute mutedID, used to drop notifications
+func (s *UserMuteStore) ExcludeMuters(ctx context.Context, mutedID int64, userIDs []int64) ([]int64, error) {
+	query := `
+		SELECT r.id FROM unnest($2::bigint[]) AS r(id)
+		WHERE ` + notMutedUserFilter("$1", "r.id") + `;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, mutedID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -7,18 +7,46 @@ import (
 	"encoding/hex"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  password `json:"-"` // do not return the password on marshalling or unmarshalling
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleID    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	ID          int64    `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Password    password `json:"-"` // do not return the password on marshalling or unmarshalling
+	CreatedAt   string   `json:"created_at"`
+	IsActive    bool     `json:"is_active"`
+	IsPrivate   bool     `json:"is_private"`
+	DisplayName string   `json:"display_name"`
+	Bio         string   `json:"bio"`
+	AvatarURL   string   `json:"avatar_url"`
+	Location    string   `json:"location"`
+	Links       []string `json:"links"`
+	RoleID      int64    `json:"role_id"`
+	Role        Role     `json:"role"`
+	// TokenGeneration is embedded in issued tokens; bumping it invalidates every token issued before
+	TokenGeneration int `json:"-"`
+	// DeactivatedAt is only loaded by GetByEmail so logging in can reactivate the account
+	DeactivatedAt *time.Time `json:"-"`
+	// TwoFactorEnabled is only loaded by GetByEmail so logging in knows to ask for a code
+	TwoFactorEnabled bool `json:"-"`
+}
+
+// UserSearchResult is the public subset of a user returned by directory search
+type UserSearchResult struct {
+	ID          int64   `json:"id"`
+	Username    string  `json:"username"`
+	CreatedAt   string  `json:"created_at"`
+	IsFollowing bool    `json:"is_following"`
+	Score       float64 `json:"score"`
+}
+
+type UserStats struct {
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+	PostsCount     int `json:"posts_count"`
 }
 
 type password struct {
@@ -40,6 +68,16 @@ func (p *password) Check(text string) bool {
 	return bcrypt.CompareHashAndPassword(p.hash, []byte(text)) == nil
 }
 
+// dummyHash is a bcrypt hash, at the cost Set uses, of a random password that was thrown away
+var dummyHash = []byte("$2a$10$YqChOYLK0KQB/n59KYnLjeFWKr.PqDTwVXeurW.5JaRKr5ruMA9se")
+
+// CheckDummyPassword takes as long as checking a real password and always fails. Logins for unknown emails use it so
+// response times don't reveal which emails have accounts
+func CheckDummyPassword(text string) bool {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(text))
+	return false
+}
+
 type UsersStore struct {
 	db *sql.DB
 }
@@ -60,7 +98,16 @@ func (s *UsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		role = "user"
 	}
 
-	err := tx.QueryRowContext(
+	held, err := usernameHeld(ctx, tx, user.Username, 0)
+	if err != nil {
+		return err
+	}
+
+	if held {
+		return ErrDuplicateUsername
+	}
+
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		user.Username,
@@ -116,7 +163,9 @@ func (s *UsersStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 
 func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT users.id, users.username, users.email, users.password, users.created_at, roles.id, roles.name, roles.description, roles.level
+		SELECT users.id, users.username, users.email, users.password, users.created_at, users.is_active, users.is_private,
+			users.display_name, users.bio, users.avatar_url, users.location, users.links, users.token_generation,
+			roles.id, roles.name, roles.description, roles.level
 		FROM users
 		JOIN roles
 		ON users.role_id = roles.id
@@ -132,7 +181,81 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		ctx,
 		query,
 		userID,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt, &user.Role.ID, &user.Role.Name, &user.Role.Description, &user.Role.Level)
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password.hash,
+		&user.CreatedAt,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Location,
+		pq.Array(&user.Links),
+		&user.TokenGeneration,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Description,
+		&user.Role.Level,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// GetByUsername looks a user up by their current username, or by a previous one that is still within its redirect
+// period
+func (s *UsersStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT users.id, users.username, users.email, users.password, users.created_at, users.is_active, users.is_private,
+			users.display_name, users.bio, users.avatar_url, users.location, users.links, users.token_generation,
+			roles.id, roles.name, roles.description, roles.level
+		FROM users
+		JOIN roles
+		ON users.role_id = roles.id
+		WHERE users.is_active = true AND users.id = COALESCE(
+			(SELECT id FROM users WHERE username = $1),
+			(SELECT user_id FROM username_history WHERE username = $1 AND expires_at > NOW())
+		);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		username,
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password.hash,
+		&user.CreatedAt,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Location,
+		pq.Array(&user.Links),
+		&user.TokenGeneration,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Description,
+		&user.Role.Level,
+	)
 
 	if err != nil {
 		switch err {
@@ -145,11 +268,69 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	return user, nil
 }
 
+// Search looks up active users whose username starts with or is similar to the query (trigram similarity).
+// Users followed by the viewer are ranked first, then prefix matches, then by similarity.
+func (s *UsersStore) Search(ctx context.Context, viewerID int64, uq UserSearchQuery) ([]UserSearchResult, error) {
+	query := `
+		SELECT u.id, u.username, u.created_at, (f.user_id IS NOT NULL) AS is_following, similarity(u.username, $2) AS score
+		FROM users u
+		LEFT JOIN followers f ON f.follower_id = u.id AND f.user_id = $1
+		WHERE u.is_active = true AND (u.username ILIKE $3 || '%' OR u.username % $2) AND
+			` + notBlockedFilter("u.id", "$1") + `
+		ORDER BY is_following DESC, (u.username ILIKE $3 || '%') DESC, score DESC, u.username
+		LIMIT $4 OFFSET $5;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, uq.Query, escapeLike(uq.Query), uq.Limit, uq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt, &u.IsFollowing, &u.Score); err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *UsersStore) GetStats(ctx context.Context, userID int64) (*UserStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.user_id WHERE f.follower_id = $1 AND u.is_active = true),
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.follower_id WHERE f.user_id = $1 AND u.is_active = true),
+			(SELECT COUNT(*) FROM posts WHERE user_id = $1);
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	stats := &UserStats{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&stats.FollowersCount, &stats.FollowingCount, &stats.PostsCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetByEmail returns an active or deactivated user so that logging in can reactivate the account. Callers that need
+// an active account must check DeactivatedAt
 func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at
+		SELECT id, username, email, password, created_at, token_generation, deactivated_at,
+			totp_enabled_at IS NOT NULL
 		FROM users
-		WHERE email = $1 AND is_active = true;
+		WHERE email = $1 AND (is_active = true OR deactivated_at IS NOT NULL);
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -161,7 +342,7 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 		ctx,
 		query,
 		email,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt)
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt, &user.TokenGeneration, &user.DeactivatedAt, &user.TwoFactorEnabled)
 
 	if err != nil {
 		switch err {
@@ -214,6 +395,331 @@ func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
 	})
 }
 
+// UpdateProfile saves the user's editable profile fields
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users SET display_name = $1, bio = $2, avatar_url = $3, location = $4, links = $5
+		WHERE id = $6 AND is_active = true;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		user.DisplayName,
+		user.Bio,
+		user.AvatarURL,
+		user.Location,
+		pq.Array(user.Links),
+		user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ChangeUsername renames the user unless they already did so within cooldown. The old username keeps resolving to
+// the account, and can't be taken by anyone else, for redirectFor
+func (s *UsersStore) ChangeUsername(ctx context.Context, userID int64, username string, cooldown, redirectFor time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var oldUsername string
+		var changedAt *time.Time
+		query := `SELECT username, username_changed_at FROM users WHERE id = $1 AND is_active = true FOR UPDATE;`
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&oldUsername, &changedAt); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if changedAt != nil && time.Since(*changedAt) < cooldown {
+			return ErrUsernameCooldown
+		}
+
+		held, err := usernameHeld(ctx, tx, username, userID)
+		if err != nil {
+			return err
+		}
+
+		if held {
+			return ErrDuplicateUsername
+		}
+
+		// Whatever history row remains for the new name is either the user's own or expired
+		if _, err := tx.ExecContext(ctx, `DELETE FROM username_history WHERE username = $1;`, username); err != nil {
+			return err
+		}
+
+		query = `UPDATE users SET username = $1, username_changed_at = NOW() WHERE id = $2;`
+		if _, err := tx.ExecContext(ctx, query, username, userID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDuplicateUsername
+			}
+			return err
+		}
+
+		query = `
+			INSERT INTO username_history (username, user_id, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (username) DO UPDATE
+			SET user_id = EXCLUDED.user_id, changed_at = NOW(), expires_at = EXCLUDED.expires_at;
+		`
+		_, err = tx.ExecContext(ctx, query, oldUsername, userID, time.Now().Add(redirectFor))
+		return err
+	})
+}
+
+// usernameHeld reports whether username is a previous username of someone other than userID that still redirects
+func usernameHeld(ctx context.Context, tx *sql.Tx, username string, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM username_history WHERE username = $1 AND user_id <> $2 AND expires_at > NOW()
+		);
+	`
+
+	var held bool
+	err := tx.QueryRowContext(ctx, query, username, userID).Scan(&held)
+	return held, err
+}
+
+// SetPrivate switches a user between a public and private account. Going public approves every pending follow
+// request since they would no longer need approval
+func (s *UsersStore) SetPrivate(ctx context.Context, userID int64, isPrivate bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET is_private = $1 WHERE id = $2;`, isPrivate, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if isPrivate {
+			return nil
+		}
+
+		query := `
+			INSERT INTO followers (user_id, follower_id)
+			SELECT requester_id, target_id FROM follow_requests WHERE target_id = $1
+			ON CONFLICT DO NOTHING;
+		`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = $1;`, userID)
+		return err
+	})
+}
+
+// CreatePasswordReset stores a hashed reset token for the user, replacing any earlier one so only the newest email
+// works
+func (s *UsersStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO password_resets (token, user_id, expiry)
+			VALUES ($1, $2, $3);
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ResetPassword sets the password of user from the owner of an unexpired reset token. The token is consumed and
+// the token generation bumped so every token issued before the reset stops working. user.ID and user.Email are
+// filled in
+func (s *UsersStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// Deleting the token claims it, so concurrent resets with the same link can't both go through
+		query := `
+			DELETE FROM password_resets WHERE token = $1 AND expiry > $2
+			RETURNING user_id;
+		`
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&user.ID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		// Other links sent to the user stop working too
+		return s.deletePasswordResets(ctx, tx, user.ID)
+	})
+}
+
+// ChangePassword saves the user's new password, revoking earlier tokens and any pending reset links
+func (s *UsersStore) ChangePassword(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.deletePasswordResets(ctx, tx, user.ID)
+	})
+}
+
+// CreateEmailChange stores a hashed confirmation token for moving the user to newEmail, replacing any earlier
+// pending change. ErrDuplicateEmail is returned if another account already uses the address
+func (s *UsersStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var taken bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1);`, newEmail).Scan(&taken)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1;`, userID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO email_changes (token, user_id, new_email, expiry)
+			VALUES ($1, $2, $3, $4);
+		`
+
+		_, err = tx.ExecContext(ctx, query, token, userID, newEmail, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ConfirmEmailChange applies the pending email change for an unexpired token and returns the user's ID
+func (s *UsersStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	var userID int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT user_id, new_email FROM email_changes WHERE token = $1 AND expiry > $2;
+		`
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var newEmail string
+		err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&userID, &newEmail)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2;`, newEmail, userID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDuplicateEmail
+			}
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1;`, userID)
+		return err
+	})
+
+	return userID, err
+}
+
+// updatePassword saves the user's password hash and bumps the token generation, which ends every session. Pending
+// email changes are dropped since they may have been requested by whoever knew the old password
+func (s *UsersStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+		UPDATE users SET password = $1, token_generation = token_generation + 1
+		WHERE id = $2
+		RETURNING token_generation, email;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, user.Password.hash, user.ID).Scan(&user.TokenGeneration, &user.Email)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	// The sessions' tokens belong to the old generation, and personal access tokens made with the old password
+	// shouldn't outlive it either
+	return execAll(ctx, tx, []string{
+		`DELETE FROM email_changes WHERE user_id = $1;`,
+		`DELETE FROM sessions WHERE user_id = $1;`,
+		`DELETE FROM access_tokens WHERE user_id = $1;`,
+	}, user.ID)
+}
+
+func (s *UsersStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		DELETE FROM password_resets WHERE user_id = $1;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
 func (s *UsersStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
 		SELECT u.id, u.username, u.email, u.created_at, u.is_active
@@ -251,7 +757,9 @@ func (s *UsersStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, toke
 
 func (s *UsersStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-		UPDATE users SET username = $1, email = $2, is_active = $3 WHERE id = $4;
+		UPDATE users SET username = $1, email = $2, is_active = $3,
+			activated_at = COALESCE(activated_at, CASE WHEN $3 THEN NOW() END)
+		WHERE id = $4;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
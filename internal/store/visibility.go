@@ -0,0 +1,15 @@
+package store
+
+// activeAuthorFilter is a WHERE clause fragment that hides content by a deactivated author in authorAlias (a users
+// row). Anonymized authors are inactive too but their content stays up under the placeholder name
+func activeAuthorFilter(authorAlias string) string {
+	return `(` + authorAlias + `.is_active = true OR ` + authorAlias + `.anonymized_at IS NOT NULL)`
+}
+
+// visibleAuthorFilter is a WHERE clause fragment that keeps content by the author in authorAlias (a users row) only
+// when the viewer in viewerParam may see it: the author is public, is the viewer, or is followed by the viewer
+func visibleAuthorFilter(authorAlias, viewerParam string) string {
+	return `(NOT ` + authorAlias + `.is_private OR ` + authorAlias + `.id = ` + viewerParam + ` OR EXISTS (
+		SELECT 1 FROM followers vf WHERE vf.user_id = ` + viewerParam + ` AND vf.follower_id = ` + authorAlias + `.id
+	))`
+}
@@ -0,0 +1,114 @@
+package syndication
+
+import (
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	RSS  Format = "rss"
+	Atom Format = "atom"
+	JSON Format = "json"
+)
+
+// ContentType returns the media type used when serving the format
+func (f Format) ContentType() string {
+	switch f {
+	case Atom:
+		return "application/atom+xml; charset=utf-8"
+	case JSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// Write encodes the feed in the given format
+func (f Format) Write(w io.Writer, feed *Feed) error {
+	switch f {
+	case Atom:
+		return WriteAtom(w, feed)
+	case JSON:
+		return WriteJSONFeed(w, feed)
+	default:
+		return WriteRSS(w, feed)
+	}
+}
+
+// FormatFromExtension maps a file extension (e.g. ".atom") to a format
+func FormatFromExtension(ext string) (Format, bool) {
+	switch strings.ToLower(ext) {
+	case ".rss", ".xml":
+		return RSS, true
+	case ".atom":
+		return Atom, true
+	case ".json":
+		return JSON, true
+	default:
+		return "", false
+	}
+}
+
+// Negotiate picks the format the Accept header weighs highest, preferring the one listed first on a tie. Types
+// with q=0 are never picked. RSS is the default
+func Negotiate(accept string) Format {
+	best, bestQ := RSS, 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var format Format
+		switch mediaType {
+		case "application/rss+xml", "application/xml", "text/xml":
+			format = RSS
+		case "application/atom+xml":
+			format = Atom
+		case "application/feed+json", "application/json":
+			format = JSON
+		default:
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(raw, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	return best
+}
+
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // human readable page for the feed
+	FeedURL     string // the URL the feed itself is served from
+	Updated     time.Time
+	Items       []Item
+}
+
+type Item struct {
+	ID        string
+	Title     string
+	Content   string
+	Link      string
+	Author    string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+}
@@ -0,0 +1,84 @@
+package syndication
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2025, 8, 8, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:   "alice on Go Social",
+		Link:    "http://localhost:5173/users/alice",
+		FeedURL: "http://localhost:8080/v1/feeds/users/alice.rss",
+		Updated: published,
+		Items: []Item{
+			{
+				ID:        "http://localhost:5173/posts/1",
+				Title:     "Fish & Chips <3",
+				Content:   "Tasty",
+				Link:      "http://localhost:5173/posts/1",
+				Author:    "alice",
+				Tags:      []string{"Cooking"},
+				Published: published,
+				Updated:   published,
+			},
+		},
+	}
+}
+
+func TestWriteFeeds(t *testing.T) {
+	for _, format := range []Format{RSS, Atom} {
+		var buf bytes.Buffer
+		if err := format.Write(&buf, testFeed()); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if err := xml.Unmarshal(buf.Bytes(), new(any)); err != nil {
+			t.Errorf("%s: produced invalid XML: %v", format, err)
+		}
+
+		if !strings.Contains(buf.String(), "Fish &amp; Chips &lt;3") {
+			t.Errorf("%s: title was not escaped: %s", format, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := JSON.Write(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("json: produced invalid JSON: %v", err)
+	}
+
+	if out["version"] != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("json: unexpected version %v", out["version"])
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]Format{
+		"":                                     RSS,
+		"application/atom+xml":                 Atom,
+		"application/feed+json, text/html":     JSON,
+		"text/html, application/rss+xml;q=0.9": RSS,
+		"*/*":                                  RSS,
+		"application/atom+xml;q=0":             RSS,
+		"application/atom+xml;q=0, */*":        RSS,
+		"application/rss+xml;q=0.5, application/feed+json;q=0.8": JSON,
+		"application/atom+xml, application/feed+json":            Atom,
+		"application/rss+xml;q=0.2, application/atom+xml;q=bad":  RSS,
+	}
+
+	for accept, want := range tests {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}